@@ -44,6 +44,31 @@ func (m MIMEType) String() string {
 	return fmt.Sprintf("%s;%s", typ, m.Options)
 }
 
+// Suffix returns the structured syntax suffix (RFC 6839) of m's
+// sub-type, if any.  For example, the suffix of
+// "application/vnd.foo+cbor" is "cbor".
+func (m MIMEType) Suffix() string {
+	suffixStart := strings.LastIndex(m.SubType, "+")
+	if suffixStart == -1 {
+		return ""
+	}
+	return m.SubType[suffixStart+1:]
+}
+
+// matchSubType returns whether or not a sub-type that a codec
+// supports matches the requested sub-type.  Codecs may support any
+// sub-type with a given structured syntax suffix by using a sub-type
+// of "*+suffix" (e.g. "*+cbor").
+func matchSubType(supported, requested MIMEType) bool {
+	if requested.SubType == "*" || requested.SubType == supported.SubType {
+		return true
+	}
+	if !strings.HasPrefix(supported.SubType, "*+") {
+		return false
+	}
+	return requested.Suffix() == supported.Suffix()
+}
+
 // ParseMIMEType parses a MIME type entry, such as from a Content-Type
 // or Accept header.
 //
@@ -146,29 +171,58 @@ func (entry *AcceptEntry) Wildcards() int {
 	return 0
 }
 
-func (entry *AcceptEntry) match(codec Codec) bool {
-	if entry.MIMEType.Type == "*" {
-		return true
+// match returns the MIME type that codec supports which matches
+// entry, and whether or not there was a match.  The returned MIME
+// type is concrete (i.e. it contains no wildcards) whenever possible,
+// so that it can be used as a Content-Type.
+func (entry *AcceptEntry) match(codec Codec) (MIMEType, bool) {
+	matched, ok := entry.matchType(codec.Types())
+	if !ok {
+		return MIMEType{}, false
 	}
-	codecTypes := codec.Types()
+	if matcher, isMatcher := codec.(Matcher); isMatcher && !matcher.Match(entry.MIMEType) {
+		return MIMEType{}, false
+	}
+	return matched, true
+}
+
+func (entry *AcceptEntry) matchType(codecTypes []MIMEType) (MIMEType, bool) {
+	var matches []MIMEType
 	for _, supported := range codecTypes {
-		if supported.Type != entry.MIMEType.Type {
-			continue
+		if entry.MIMEType.Type != "*" {
+			if supported.Type != entry.MIMEType.Type || !matchSubType(supported, entry.MIMEType) {
+				continue
+			}
 		}
-		if entry.MIMEType.SubType == "*" || entry.MIMEType.SubType == supported.SubType {
-			return true
+		matches = append(matches, supported)
+	}
+	if len(matches) == 0 {
+		return MIMEType{}, false
+	}
+	for _, supported := range matches {
+		if !strings.HasPrefix(supported.SubType, "*") {
+			return supported, true
 		}
 	}
-	return false
+	supported := matches[0]
+	if entry.Wildcards() == 0 {
+		matched := entry.MIMEType
+		matched.Options = supported.Options
+		return matched, true
+	}
+	// Neither entry nor the codec's matching types are concrete, so
+	// fall back to the type named by the suffix (e.g. application/cbor
+	// for application/*+cbor).
+	return MIMEType{Type: supported.Type, SubType: supported.Suffix(), Options: supported.Options}, true
 }
 
-func (entry *AcceptEntry) bestCodec(codecs []Codec) Codec {
+func (entry *AcceptEntry) bestCodec(codecs []Codec) (Codec, MIMEType) {
 	for _, codec := range codecs {
-		if entry.match(codec) {
-			return codec.New(entry.MIMEType)
+		if matched, ok := entry.match(codec); ok {
+			return codec.New(entry.MIMEType), matched
 		}
 	}
-	return nil
+	return nil, MIMEType{}
 }
 
 // Accept stores all values in an Accept header.
@@ -231,25 +285,50 @@ func ParseAcceptHeader(header http.Header) Accept {
 	return accept
 }
 
+// ParseContentType loads the Content-Type header from an http.Header
+// value, then parses it into a MIMEType value.  Since Content-Type
+// doesn't allow accept-params, any options following a "q" option
+// are kept in the returned value's Options.
+func ParseContentType(header http.Header) MIMEType {
+	mime, extra := ParseMIMEType(strings.TrimSpace(header.Get("Content-Type")))
+	for k, v := range extra {
+		mime.Options.Add(k, v)
+	}
+	return mime
+}
+
+// ContentTypeCodec returns the codec in codecs that matches the
+// Content-Type header in header, or nil if there is no matching
+// codec.
+func ContentTypeCodec(header http.Header, codecs []Codec) Codec {
+	mime := ParseContentType(header)
+	if mime.Type == "" || mime.Type == "*" || mime.SubType == "*" {
+		// Wildcards are not valid in a Content-Type header.
+		return nil
+	}
+	entry := &AcceptEntry{MIMEType: mime}
+	codec, _ := entry.bestCodec(codecs)
+	return codec
+}
+
 // Codec returns the best codec in codecs for this accept header.  It
 // automatically sorts the entries based on RFC2616 section 14.1 prior
 // to ranging through them, to ensure the codec it loads is optimal
 // for the Accept header.
 func (accept Accept) Codec(codecs []Codec) Codec {
-	if len(codecs) > 0 {
-		return accept.bestCodec(codecs)
-	}
-	return nil
+	codec, _ := accept.bestCodec(codecs)
+	return codec
 }
 
-func (accept Accept) bestCodec(codecs []Codec) Codec {
+// bestCodec returns the best codec in codecs for this accept header,
+// along with the MIME type that it was matched with.
+func (accept Accept) bestCodec(codecs []Codec) (Codec, MIMEType) {
 	for _, entry := range accept {
-		codec := entry.bestCodec(codecs)
-		if codec != nil {
-			return codec
+		if codec, matched := entry.bestCodec(codecs); codec != nil {
+			return codec, matched
 		}
 	}
-	return nil
+	return nil, MIMEType{}
 }
 
 func isOptionSplit(r rune) bool {
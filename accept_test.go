@@ -86,6 +86,17 @@ var _ = Describe("Accept", func() {
 				Expect(acceptOptions).To(BeEquivalentTo(expectedAcceptOptions))
 			})
 		})
+
+		Context("Suffix", func() {
+			BeforeEach(func() {
+				mimeString = "application/vnd.foo+cbor"
+			})
+
+			It("returns the structured syntax suffix", func() {
+				Expect(mime.SubType).To(Equal("vnd.foo+cbor"))
+				Expect(mime.Suffix()).To(Equal("cbor"))
+			})
+		})
 	})
 
 	Context("AcceptEntry Parsing", func() {
@@ -327,6 +338,18 @@ var _ = Describe("Accept", func() {
 				Expect(accept.Codec([]silverback.Codec{mockJSON})).To(Equal(mockJSON))
 			})
 		})
+
+		Context("Suffix Match", func() {
+			var mockSuffix = makeCodec("application", "*+json")
+
+			It("matches any sub-type with the codec's suffix", func() {
+				accept = silverback.Accept{silverback.ParseAcceptEntry("application/hal+json")}
+				Expect(accept.Codec([]silverback.Codec{mockSuffix})).To(Equal(mockSuffix))
+
+				accept = silverback.Accept{silverback.ParseAcceptEntry("application/hal+xml")}
+				Expect(accept.Codec([]silverback.Codec{mockSuffix})).To(BeNil())
+			})
+		})
 	})
 })
 
@@ -1,5 +1,7 @@
 package silverback
 
+import "net/http"
+
 // A Codec contains methods for marshaling and unmarshaling data.
 type Codec interface {
 	// New takes a MIMEType that was matched against this codec and
@@ -15,3 +17,38 @@ type Codec interface {
 	Marshal(target interface{}) ([]byte, error)
 	Unmarshal(raw []byte, targetAddr interface{}) error
 }
+
+// A RequestCodec is a Codec that needs information about the request
+// that it is responding to, such as the request's method or the
+// resource it was routed to (see RequestResource).  After a
+// RequestCodec is matched against the Accept header and New has been
+// called, ForRequest will be called on the new codec, and the
+// returned codec will be used for the response.
+type RequestCodec interface {
+	Codec
+	ForRequest(*http.Request) Codec
+}
+
+// A Matcher is a Codec that needs to inspect the parameters of a
+// requested MIME type before deciding whether or not it can handle
+// it.  Match will only be called with MIME types that have already
+// been matched against one of the codec's Types(); if it returns
+// false, the codec will be skipped.
+type Matcher interface {
+	Codec
+	Match(requested MIMEType) bool
+}
+
+// A StreamCodec is a Codec that can write response bodies one item at
+// a time.  When a Response's Body is a stream (see Response.Body) and
+// its codec is a StreamCodec, each item will be marshalled and
+// written to the client as soon as it is received, rather than
+// collecting all of the items and marshalling them at once.
+type StreamCodec interface {
+	Codec
+
+	// MarshalItem marshals a single item from a streamed body,
+	// including any framing (such as a trailing newline) needed to
+	// separate it from the items that follow it.
+	MarshalItem(item interface{}) ([]byte, error)
+}
@@ -0,0 +1,101 @@
+package codecs
+
+import (
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/nelsam/silverback"
+)
+
+// CBOR is a codec that handles CBOR (RFC 8949) marshalling and
+// unmarshalling.  Struct fields are encoded using their `cbor` tags,
+// falling back to their `json` tags, so types that are already set
+// up for the JSON codec can usually be used as-is.
+//
+// Encoding is deterministic, following the core deterministic
+// encoding requirements of RFC 8949 section 4.2.1.  time.Time values
+// are encoded as epoch-based date/time values (tag 1) and big.Int
+// values that don't fit in a CBOR integer are encoded as bignums
+// (tags 2 and 3).
+//
+// Since request bodies are not trusted, decoding is limited by
+// MaxNestedLevels, MaxArrayElements, and MaxMapPairs.  A zero value
+// for any of them will use the default limit from
+// github.com/fxamacker/cbor.
+type CBOR struct {
+	MaxNestedLevels  int
+	MaxArrayElements int
+	MaxMapPairs      int
+
+	init sync.Once
+	enc  cbor.EncMode
+	dec  cbor.DecMode
+	err  error
+}
+
+// New returns c.  The CBOR codec has no per-request context, so
+// there's no need to use a separate copy across threads.
+func (c *CBOR) New(silverback.MIMEType) silverback.Codec {
+	return c
+}
+
+// Types returns the MIME types that this codec is capable of
+// handling: application/cbor, as well as any application type using
+// the +cbor structured syntax suffix.
+func (c *CBOR) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "application",
+			SubType: "cbor",
+		},
+		{
+			Type:    "application",
+			SubType: "*+cbor",
+		},
+	}
+}
+
+// modes loads the encoding and decoding modes for c, creating them
+// the first time it is called.
+func (c *CBOR) modes() (cbor.EncMode, cbor.DecMode, error) {
+	c.init.Do(func() {
+		encOpts := cbor.CoreDetEncOptions()
+		encOpts.Time = cbor.TimeUnixDynamic
+		encOpts.TimeTag = cbor.EncTagRequired
+		encOpts.BigIntConvert = cbor.BigIntConvertShortest
+		c.enc, c.err = encOpts.EncMode()
+		if c.err != nil {
+			return
+		}
+		decOpts := cbor.DecOptions{
+			TimeTag:          cbor.DecTagOptional,
+			MaxNestedLevels:  c.MaxNestedLevels,
+			MaxArrayElements: c.MaxArrayElements,
+			MaxMapPairs:      c.MaxMapPairs,
+		}
+		c.dec, c.err = decOpts.DecMode()
+	})
+	return c.enc, c.dec, c.err
+}
+
+// Marshal marshals target to CBOR, returning the bytes and any errors
+// encountered.
+func (c *CBOR) Marshal(target interface{}) ([]byte, error) {
+	enc, _, err := c.modes()
+	if err != nil {
+		return nil, err
+	}
+	return enc.Marshal(target)
+}
+
+// Unmarshal unmarshals CBOR data to the value that is pointed to by
+// targetAddr, which must be a pointer.  It returns any errors
+// encountered, including errors for data that exceeds c's decoding
+// limits.
+func (c *CBOR) Unmarshal(raw []byte, targetAddr interface{}) error {
+	_, dec, err := c.modes()
+	if err != nil {
+		return err
+	}
+	return dec.Unmarshal(raw, targetAddr)
+}
@@ -0,0 +1,105 @@
+package codecs_test
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type cborSample struct {
+	Name    string    `json:"name"`
+	Count   int       `json:"count,omitempty"`
+	Created time.Time `json:"created"`
+	Big     *big.Int  `json:"big"`
+}
+
+var _ = Describe("CBOR", func() {
+	var codec silverback.Codec
+
+	BeforeEach(func() {
+		codec = &codecs.CBOR{}
+	})
+
+	It("returns a cbor codec when a new copy is requested", func() {
+		newCodec := codec.New(silverback.MIMEType{})
+		_, isCBOR := newCodec.(*codecs.CBOR)
+		Expect(isCBOR).To(BeTrue())
+	})
+
+	It("is matched by application/cbor and +cbor MIME types", func() {
+		for _, accept := range []string{"application/cbor", "application/vnd.sensor+cbor", "application/*"} {
+			entry := silverback.ParseAcceptEntry(accept)
+			Expect(silverback.Accept{entry}.Codec([]silverback.Codec{codec})).To(Equal(codec))
+		}
+		entry := silverback.ParseAcceptEntry("application/vnd.sensor+json")
+		Expect(silverback.Accept{entry}.Codec([]silverback.Codec{codec})).To(BeNil())
+	})
+
+	It("uses json struct tags", func() {
+		raw, err := codec.Marshal(cborSample{Name: "foo"})
+		Expect(err).ToNot(HaveOccurred())
+		var m map[string]interface{}
+		Expect(cbor.Unmarshal(raw, &m)).To(Succeed())
+		Expect(m).To(HaveKeyWithValue("name", "foo"))
+		Expect(m).ToNot(HaveKey("count"))
+	})
+
+	It("encodes deterministically", func() {
+		val := map[string]int{"b": 2, "a": 1, "aa": 3}
+		first, err := codec.Marshal(val)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			Expect(codec.Marshal(val)).To(Equal(first))
+		}
+		// Core deterministic encoding sorts map keys by their encoded
+		// bytes, which puts shorter keys first.
+		expected := []byte{0xa3, 0x61, 'a', 0x01, 0x61, 'b', 0x02, 0x62, 'a', 'a', 0x03}
+		Expect(first).To(Equal(expected))
+	})
+
+	It("round trips timestamps and big numbers", func() {
+		n, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+		Expect(ok).To(BeTrue())
+		val := cborSample{
+			Name:    "sensor",
+			Count:   3,
+			Created: time.Unix(1700000000, 0).UTC(),
+			Big:     n,
+		}
+		raw, err := codec.Marshal(val)
+		Expect(err).ToNot(HaveOccurred())
+
+		var actual cborSample
+		Expect(codec.Unmarshal(raw, &actual)).To(Succeed())
+		Expect(actual.Name).To(Equal(val.Name))
+		Expect(actual.Count).To(Equal(val.Count))
+		Expect(actual.Created.Equal(val.Created)).To(BeTrue())
+		Expect(actual.Big.Cmp(val.Big)).To(BeZero())
+	})
+
+	It("limits nesting depth when decoding", func() {
+		codec = &codecs.CBOR{MaxNestedLevels: 4}
+		var nested interface{} = "leaf"
+		for i := 0; i < 10; i++ {
+			nested = []interface{}{nested}
+		}
+		raw, err := cbor.Marshal(nested)
+		Expect(err).ToNot(HaveOccurred())
+		var actual interface{}
+		Expect(codec.Unmarshal(raw, &actual)).ToNot(Succeed())
+	})
+
+	It("limits array sizes when decoding", func() {
+		codec = &codecs.CBOR{MaxArrayElements: 16}
+		raw, err := cbor.Marshal(make([]int, 17))
+		Expect(err).ToNot(HaveOccurred())
+		var actual []int
+		Expect(codec.Unmarshal(raw, &actual)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,25 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/nelsam/silverback/codecs"
+	"github.com/nelsam/silverback/codectest"
+)
+
+type conformanceValue struct {
+	Name    string
+	Count   int64
+	Ratio   float64
+	Enabled bool
+	Tags    []string
+	Scores  map[string]int
+	Parent  *conformanceValue
+}
+
+func TestJSONConformance(t *testing.T) {
+	codectest.Suite{
+		Codec:   &codecs.JSON{},
+		Samples: []interface{}{conformanceValue{}, "", 0, []float64{}},
+	}.Run(t)
+}
@@ -0,0 +1,69 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/nelsam/silverback"
+)
+
+// Gob is a codec that handles encoding/gob marshalling and
+// unmarshalling.  It's intended for Go clients talking to Go
+// services, where both sides share the types being sent.
+//
+// Types that are sent as interface values (including values in
+// interface{} fields) must be registered on both sides; see
+// RegisterGob and RegisterGobName.
+type Gob struct{}
+
+// RegisterGob registers the concrete types of values with
+// encoding/gob, so that they can be sent as interface values.
+func RegisterGob(values ...interface{}) {
+	for _, value := range values {
+		gob.Register(value)
+	}
+}
+
+// RegisterGobName registers the concrete type of value with
+// encoding/gob under name, so that it can be sent as an interface
+// value.  This is useful when clients and services don't share the
+// same package paths for their types.
+func RegisterGobName(name string, value interface{}) {
+	gob.RegisterName(name, value)
+}
+
+// New returns g.  The Gob codec has no per-request context, so
+// there's no need to use a separate copy across threads.
+func (g *Gob) New(silverback.MIMEType) silverback.Codec {
+	return g
+}
+
+// Types returns the MIME types that this codec is capable of
+// handling.
+func (g *Gob) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "application",
+			SubType: "x-gob",
+		},
+	}
+}
+
+// Marshal marshals target to a gob stream, returning the bytes and
+// any errors encountered.  Each call uses a new encoder, so the
+// returned bytes include all of the type information needed to
+// decode them.
+func (g *Gob) Marshal(target interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(target); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal unmarshals a gob stream to the value that is pointed to
+// by targetAddr, which must be a pointer.  It returns any errors
+// encountered.
+func (g *Gob) Unmarshal(raw []byte, targetAddr interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(targetAddr)
+}
@@ -0,0 +1,62 @@
+package codecs_test
+
+import (
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type gobSample struct {
+	Name  string
+	Count int
+	Extra interface{}
+}
+
+type gobExtra struct {
+	Value string
+}
+
+var _ = Describe("Gob", func() {
+	var codec silverback.Codec
+
+	BeforeEach(func() {
+		codec = &codecs.Gob{}
+	})
+
+	It("returns a gob codec when a new copy is requested", func() {
+		newCodec := codec.New(silverback.MIMEType{})
+		_, isGob := newCodec.(*codecs.Gob)
+		Expect(isGob).To(BeTrue())
+	})
+
+	It("supports the application/x-gob MIME type", func() {
+		gobType, _ := silverback.ParseMIMEType("application/x-gob")
+		Expect(codec.Types()).To(ConsistOf(gobType))
+	})
+
+	It("round trips typed values", func() {
+		val := gobSample{Name: "foo", Count: 3}
+		raw, err := codec.Marshal(val)
+		Expect(err).ToNot(HaveOccurred())
+		var actual gobSample
+		Expect(codec.Unmarshal(raw, &actual)).To(Succeed())
+		Expect(actual).To(Equal(val))
+	})
+
+	It("round trips registered interface values", func() {
+		codecs.RegisterGob(gobExtra{})
+		val := gobSample{Name: "foo", Extra: gobExtra{Value: "bar"}}
+		raw, err := codec.Marshal(val)
+		Expect(err).ToNot(HaveOccurred())
+		var actual gobSample
+		Expect(codec.Unmarshal(raw, &actual)).To(Succeed())
+		Expect(actual.Extra).To(Equal(gobExtra{Value: "bar"}))
+	})
+
+	It("errors from improper gob data", func() {
+		var actual gobSample
+		Expect(codec.Unmarshal([]byte("not a gob"), &actual)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,185 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+
+	"github.com/nelsam/silverback"
+)
+
+// HAL is a codec that handles JSON Hypertext Application Language
+// (application/hal+json) representations.  Bodies are marshalled as
+// JSON, then decorated with _links and _embedded members using the
+// resource that the request was routed to:
+//
+//   - Objects get a "self" link to the resource, along with any links
+//     added by a silverback.Linker handler.
+//   - Slices and arrays (such as Query results) become an object with
+//     a "self" link to the collection, with the items embedded under
+//     the resource's name (the last element of its Path()).  Items
+//     implementing silverback.Identified get their own "self" links,
+//     when the resource supports Get.
+//
+// The JSON field configures how bodies are marshalled and
+// unmarshalled, and is overridden by MIME type parameters in the same
+// way as the JSON codec.
+type HAL struct {
+	JSON JSON
+
+	resource *silverback.Resource
+}
+
+type halLink struct {
+	Href  string `json:"href"`
+	Title string `json:"title,omitempty"`
+}
+
+// New returns a copy of h, configured using the parameters of
+// matched.
+func (h *HAL) New(matched silverback.MIMEType) silverback.Codec {
+	return &HAL{
+		JSON: *h.JSON.New(matched).(*JSON),
+	}
+}
+
+// ForRequest returns a copy of h that will build links using the
+// resource that r was routed to.
+func (h *HAL) ForRequest(r *http.Request) silverback.Codec {
+	c := *h
+	if resource, ok := silverback.RequestResource(r); ok {
+		c.resource = &resource
+	}
+	return &c
+}
+
+// Match returns false if requested has a charset parameter that isn't
+// UTF-8.
+func (h *HAL) Match(requested silverback.MIMEType) bool {
+	return h.JSON.Match(requested)
+}
+
+// Types returns the MIME types that this codec is capable of handling.
+func (h *HAL) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "application",
+			SubType: "hal+json",
+		},
+	}
+}
+
+// Marshal marshals target to HAL, returning the bytes and any errors
+// encountered.  Problem details are marshalled as plain JSON.
+func (h *HAL) Marshal(target interface{}) ([]byte, error) {
+	if _, isProblem := target.(*silverback.Problem); isProblem || h.resource == nil {
+		return h.JSON.Marshal(target)
+	}
+	v := reflect.ValueOf(target)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return h.marshalCollection(v)
+	}
+	links := h.links(h.selfPath(target), h.resource.Links)
+	doc, err := h.decorate(target, links)
+	if err != nil {
+		return nil, err
+	}
+	return h.JSON.Marshal(doc)
+}
+
+func (h *HAL) marshalCollection(v reflect.Value) ([]byte, error) {
+	items := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i).Interface()
+		var links map[string]interface{}
+		if identified, ok := item.(silverback.Identified); ok && h.resource.ItemRoute {
+			links = h.links(h.resource.ItemPath(identified.ID()), nil)
+		}
+		decorated, err := h.decorate(item, links)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, decorated)
+	}
+	doc := map[string]interface{}{
+		"_links": h.links(h.resource.CollectionPath(), h.resource.Links),
+		"_embedded": map[string]interface{}{
+			path.Base(h.resource.Path): items,
+		},
+	}
+	return h.JSON.Marshal(doc)
+}
+
+// selfPath returns the path that the "self" link of target should
+// point to.
+func (h *HAL) selfPath(target interface{}) string {
+	if h.resource.ID != "" {
+		return h.resource.ItemPath(h.resource.ID)
+	}
+	if identified, ok := target.(silverback.Identified); ok && h.resource.ItemRoute {
+		return h.resource.ItemPath(identified.ID())
+	}
+	return h.resource.CollectionPath()
+}
+
+// links builds a _links object with a self link and any extra links.
+// Relations with more than one link are rendered as arrays.
+func (h *HAL) links(self string, extra []silverback.Link) map[string]interface{} {
+	rels := map[string][]halLink{
+		"self": {{Href: self}},
+	}
+	for _, link := range extra {
+		rels[link.Rel] = append(rels[link.Rel], halLink{Href: link.Href, Title: link.Title})
+	}
+	links := make(map[string]interface{}, len(rels))
+	for rel, relLinks := range rels {
+		if len(relLinks) == 1 {
+			links[rel] = relLinks[0]
+			continue
+		}
+		links[rel] = relLinks
+	}
+	return links
+}
+
+// decorate adds links to the JSON representation of target.  Values
+// that aren't JSON objects can't hold links, so they are returned
+// as-is.
+func (h *HAL) decorate(target interface{}, links map[string]interface{}) (interface{}, error) {
+	raw, err := h.JSON.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(links) == 0 || len(raw) == 0 || raw[0] != '{' {
+		return json.RawMessage(raw), nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{}, len(members)+1)
+	for name, value := range members {
+		doc[name] = value
+	}
+	doc["_links"] = links
+	return doc, nil
+}
+
+// Unmarshal unmarshals a HAL document to the value that is pointed to
+// by targetAddr, ignoring its _links and _embedded members.
+func (h *HAL) Unmarshal(raw []byte, targetAddr interface{}) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return h.JSON.Unmarshal(raw, targetAddr)
+	}
+	delete(members, "_links")
+	delete(members, "_embedded")
+	stripped, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	return h.JSON.Unmarshal(stripped, targetAddr)
+}
@@ -0,0 +1,123 @@
+package codecs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type order struct {
+	OrderID string `json:"id"`
+	Total   int    `json:"total"`
+}
+
+func (o order) ID() string {
+	return o.OrderID
+}
+
+type orderHandler struct {
+	req *http.Request
+}
+
+func (h *orderHandler) New(r *http.Request) silverback.Handler {
+	return &orderHandler{req: r}
+}
+
+func (h *orderHandler) Path() string {
+	return "/orders"
+}
+
+func (h *orderHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = order{OrderID: id, Total: 10}
+	return resp
+}
+
+func (h *orderHandler) Query() *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = []order{{OrderID: "1", Total: 10}, {OrderID: "2", Total: 20}}
+	return resp
+}
+
+func (h *orderHandler) Links() []silverback.Link {
+	return []silverback.Link{
+		{Rel: "help", Href: "/docs/orders", Title: "Order docs"},
+	}
+}
+
+var _ = Describe("HAL", func() {
+	var (
+		codec  *codecs.HAL
+		router *silverback.Router
+	)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Accept", "application/hal+json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		codec = &codecs.HAL{}
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSON{})
+		router.AddCodec(codec)
+		router.Route(&orderHandler{})
+	})
+
+	It("supports the application/hal+json MIME type", func() {
+		hal, _ := silverback.ParseMIMEType("application/hal+json")
+		Expect(codec.Types()).To(ConsistOf(hal))
+	})
+
+	It("marshals plain json without request context", func() {
+		Expect(codec.Marshal(order{OrderID: "1"})).To(MatchJSON(`{"id":"1","total":0}`))
+	})
+
+	It("adds self and custom links to items", func() {
+		recorder := get("/orders/42")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/hal+json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"id": "42",
+			"total": 10,
+			"_links": {
+				"self": {"href": "/orders/42"},
+				"help": {"href": "/docs/orders", "title": "Order docs"}
+			}
+		}`))
+	})
+
+	It("embeds collection items with links to each item", func() {
+		recorder := get("/orders")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"_links": {
+				"self": {"href": "/orders"},
+				"help": {"href": "/docs/orders", "title": "Order docs"}
+			},
+			"_embedded": {
+				"orders": [
+					{"id": "1", "total": 10, "_links": {"self": {"href": "/orders/1"}}},
+					{"id": "2", "total": 20, "_links": {"self": {"href": "/orders/2"}}}
+				]
+			}
+		}`))
+	})
+
+	It("ignores links when unmarshalling", func() {
+		var actual order
+		raw := []byte(`{"id":"1","total":5,"_links":{"self":{"href":"/orders/1"}}}`)
+		mime, _ := silverback.ParseMIMEType("application/hal+json; disallow-unknown-fields")
+		Expect(codec.New(mime).Unmarshal(raw, &actual)).To(Succeed())
+		Expect(actual).To(Equal(order{OrderID: "1", Total: 5}))
+	})
+})
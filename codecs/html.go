@@ -0,0 +1,159 @@
+package codecs
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/nelsam/silverback"
+)
+
+const defaultHTMLExt = ".html"
+
+// A Templated value chooses its own template when it is rendered by
+// the HTML codec.
+type Templated interface {
+	// Template returns the path of the page template, relative to
+	// the HTML codec's FS, that should be used to render this value.
+	Template() string
+}
+
+// HTML is a codec that renders response bodies through html/template,
+// so that the same handlers can serve both API clients and browsers.
+//
+// Page templates are loaded from FS.  If the body being rendered
+// implements Templated, its template will be used; otherwise, the
+// template is chosen using the resource and handler method that the
+// request was routed to, as "<handler path>/<method><Ext>".  For
+// example, a Getter with a Path() of "/users" will render using
+// "users/get.html", while its Query method would render using
+// "users/query.html".  The same templates are used wherever the
+// handler is routed, including by groups and child routers.
+//
+// If Layout is set, it is parsed along with each page template and
+// executed in place of the page; pages should then define the
+// templates that the layout uses, e.g. {{define "content"}}.
+type HTML struct {
+	FS     fs.FS
+	Layout string
+	Ext    string
+	Funcs  template.FuncMap
+
+	templates sync.Map
+}
+
+// New returns h.  Request-specific context is added by ForRequest.
+func (h *HTML) New(silverback.MIMEType) silverback.Codec {
+	return h
+}
+
+// ForRequest returns a codec that will render using the page template
+// for the resource and method that r was routed to.
+func (h *HTML) ForRequest(r *http.Request) silverback.Codec {
+	page := &htmlPage{HTML: h}
+	if resource, ok := silverback.RequestResource(r); ok {
+		page.name = h.pageName(resource)
+	}
+	return page
+}
+
+// Types returns the MIME types that this codec is capable of handling.
+func (h *HTML) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "text",
+			SubType: "html",
+			Options: silverback.Options{"charset": "utf-8"},
+		},
+	}
+}
+
+// Marshal renders target.  Since h has no request context, target
+// must implement Templated.
+func (h *HTML) Marshal(target interface{}) ([]byte, error) {
+	return h.render("", target)
+}
+
+// Unmarshal always returns an error, since HTML is only supported for
+// responses.
+func (h *HTML) Unmarshal(raw []byte, targetAddr interface{}) error {
+	return errors.New("html: unmarshalling is not supported")
+}
+
+func (h *HTML) ext() string {
+	if h.Ext == "" {
+		return defaultHTMLExt
+	}
+	return h.Ext
+}
+
+// pageName returns the name of the page template for resource.  It
+// uses the handler's own Path(), rather than resource.Path, so that
+// the identifiers of parent resources and group prefixes don't end up
+// in template names.
+func (h *HTML) pageName(resource silverback.Resource) string {
+	name := strings.ToLower(resource.Method) + h.ext()
+	resourcePath := resource.Path
+	if resource.Handler != nil {
+		resourcePath = resource.Handler.Path()
+	}
+	return path.Join(strings.Trim(resourcePath, "/"), name)
+}
+
+// render renders target using the page template named by page, unless
+// target implements Templated.
+func (h *HTML) render(page string, target interface{}) ([]byte, error) {
+	if templated, ok := target.(Templated); ok {
+		page = templated.Template()
+	}
+	if page == "" {
+		return nil, errors.New("html: no template found for response body")
+	}
+	tmpl, err := h.template(page)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(page)
+	if h.Layout != "" {
+		name = path.Base(h.Layout)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, target); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// template loads the template for page, parsing it (along with the
+// layout, if any) the first time it's requested.
+func (h *HTML) template(page string) (*template.Template, error) {
+	if cached, ok := h.templates.Load(page); ok {
+		return cached.(*template.Template), nil
+	}
+	files := []string{page}
+	if h.Layout != "" {
+		files = []string{h.Layout, page}
+	}
+	tmpl, err := template.New(path.Base(page)).Funcs(h.Funcs).ParseFS(h.FS, files...)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := h.templates.LoadOrStore(page, tmpl)
+	return actual.(*template.Template), nil
+}
+
+// htmlPage is an HTML codec that has been matched to a request.
+type htmlPage struct {
+	*HTML
+	name string
+}
+
+// Marshal renders target using p's page template.
+func (p *htmlPage) Marshal(target interface{}) ([]byte, error) {
+	return p.render(p.name, target)
+}
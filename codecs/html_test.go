@@ -0,0 +1,147 @@
+package codecs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing/fstest"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type page struct {
+	Title string `json:"title"`
+}
+
+type templatedPage struct {
+	page
+}
+
+func (templatedPage) Template() string {
+	return "custom.html"
+}
+
+type pageHandler struct {
+	req *http.Request
+}
+
+func (h *pageHandler) New(r *http.Request) silverback.Handler {
+	return &pageHandler{req: r}
+}
+
+func (h *pageHandler) Path() string {
+	return "/pages"
+}
+
+func (h *pageHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Status = http.StatusOK
+	resp.Body = page{Title: id}
+	return resp
+}
+
+type bookHandler struct{}
+
+func (h *bookHandler) New(*http.Request) silverback.Handler {
+	return h
+}
+
+func (h *bookHandler) Path() string {
+	return "/books"
+}
+
+var _ = Describe("HTML", func() {
+	var (
+		codec *codecs.HTML
+		files fstest.MapFS
+	)
+
+	BeforeEach(func() {
+		files = fstest.MapFS{
+			"layout.html":    {Data: []byte(`<html>{{template "content" .}}</html>`)},
+			"custom.html":    {Data: []byte(`{{define "content"}}<p>{{.Title}}</p>{{end}}`)},
+			"pages/get.html": {Data: []byte(`{{define "content"}}<h1>{{.Title}}</h1>{{end}}`)},
+		}
+		codec = &codecs.HTML{FS: files}
+	})
+
+	It("supports the text/html MIME type", func() {
+		Expect(codec.Types()).To(HaveLen(1))
+		Expect(codec.Types()[0].Type).To(Equal("text"))
+		Expect(codec.Types()[0].SubType).To(Equal("html"))
+	})
+
+	It("renders bodies that choose their own template", func() {
+		files["custom.html"] = &fstest.MapFile{Data: []byte(`<p>{{.Title}}</p>`)}
+		Expect(codec.Marshal(templatedPage{page{Title: "<foo>"}})).To(BeEquivalentTo("<p>&lt;foo&gt;</p>"))
+	})
+
+	It("renders pages inside of a layout", func() {
+		codec.Layout = "layout.html"
+		Expect(codec.Marshal(templatedPage{page{Title: "foo"}})).To(BeEquivalentTo("<html><p>foo</p></html>"))
+	})
+
+	It("errors when there is no template to render", func() {
+		_, err := codec.Marshal(page{Title: "foo"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not support unmarshalling", func() {
+		var p page
+		Expect(codec.Unmarshal([]byte("<p>foo</p>"), &p)).ToNot(Succeed())
+	})
+
+	Context("Routed", func() {
+		var router *silverback.Router
+
+		BeforeEach(func() {
+			codec.Layout = "layout.html"
+			router = silverback.NewRouter()
+			router.AddCodec(&codecs.JSON{})
+			router.AddCodec(codec)
+			router.Route(&pageHandler{})
+		})
+
+		It("renders the resource's template for browsers", func() {
+			req, err := http.NewRequest("GET", "/pages/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "text/html, application/xhtml+xml, */*; q=0.8")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/html;charset=utf-8"))
+			Expect(recorder.Body.String()).To(Equal("<html><h1>foo</h1></html>"))
+		})
+
+		It("uses the handler's template for nested and grouped routes", func() {
+			router.Group("/v1").Sub(&bookHandler{}).Route(&pageHandler{})
+			req, err := http.NewRequest("GET", "/v1/books/1/pages/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "text/html")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal("<html><h1>foo</h1></html>"))
+		})
+
+		It("serves json to API clients from the same handler", func() {
+			req, err := http.NewRequest("GET", "/pages/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			expected, err := json.Marshal(page{Title: "foo"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Body.String()).To(MatchJSON(expected))
+		})
+	})
+})
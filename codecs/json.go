@@ -1,19 +1,80 @@
 package codecs
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
 
 	"github.com/nelsam/silverback"
 )
 
+const defaultIndent = "  "
+
 // JSON is a codec that handles json marshalling and unmarshalling.
-type JSON struct{}
+//
+// The fields on a JSON value act as defaults; the value registered
+// with a Router is never modified.  Instead, New returns a copy with
+// any options from the matched MIME type's parameters applied, so
+// each request gets its own configuration.  The supported parameters
+// are:
+//
+//   - pretty: indent output using two spaces.
+//   - indent: indent output using the given number of spaces, or
+//     "tab" to indent using tabs.
+//   - escape-html: whether or not to escape HTML characters in
+//     strings.
+//   - disallow-unknown-fields: error when decoding objects with keys
+//     that don't match any field in the target.
+//   - use-number: decode numbers into json.Number instead of float64.
+//
+// Boolean parameters with no value (e.g. "application/json; pretty")
+// are treated as true.  JSON is always UTF-8 (RFC 8259), so MIME
+// types with any other charset parameter will not be matched.
+type JSON struct {
+	Indent                string
+	DisableHTMLEscaping   bool
+	DisallowUnknownFields bool
+	UseNumber             bool
+}
+
+// New returns a copy of j, configured using the parameters of
+// matched.
+func (j *JSON) New(matched silverback.MIMEType) silverback.Codec {
+	c := *j
+	for name, value := range matched.Options {
+		switch strings.ToLower(name) {
+		case "pretty":
+			switch {
+			case !parseBool(value, c.Indent != ""):
+				c.Indent = ""
+			case c.Indent == "":
+				c.Indent = defaultIndent
+			}
+		case "indent":
+			c.Indent = parseIndent(value, c.Indent)
+		case "escape-html":
+			c.DisableHTMLEscaping = !parseBool(value, !c.DisableHTMLEscaping)
+		case "disallow-unknown-fields":
+			c.DisallowUnknownFields = parseBool(value, c.DisallowUnknownFields)
+		case "use-number":
+			c.UseNumber = parseBool(value, c.UseNumber)
+		}
+	}
+	return &c
+}
 
-// New returns j.  This is because the JSON codec currently has no
-// context to alter, so there's no need to use a separate copy across
-// threads.
-func (j *JSON) New(silverback.MIMEType) silverback.Codec {
-	return j
+// Match returns false if requested has a charset parameter that isn't
+// UTF-8.
+func (j *JSON) Match(requested silverback.MIMEType) bool {
+	charset, ok := requested.Options["charset"]
+	if !ok {
+		return true
+	}
+	charset = strings.ToLower(strings.Trim(charset, `"`))
+	return charset == "utf-8" || charset == "utf8"
 }
 
 // Types returns the MIME types that this codec is capable of handling.
@@ -33,12 +94,69 @@ func (j *JSON) Types() []silverback.MIMEType {
 // Marshal marshals target to a JSON string, returning the bytes and
 // any errors encountered.
 func (j *JSON) Marshal(target interface{}) ([]byte, error) {
-	return json.Marshal(target)
+	if j.Indent == "" && !j.DisableHTMLEscaping {
+		return json.Marshal(target)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", j.Indent)
+	enc.SetEscapeHTML(!j.DisableHTMLEscaping)
+	if err := enc.Encode(target); err != nil {
+		return nil, err
+	}
+	// json.Encoder always terminates values with a newline, but
+	// json.Marshal doesn't.
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // Unmarshal unmarshals a JSON string to the value that is pointed to
 // by targetAddr, which must be a pointer.  It returns any errors
 // encountered.
 func (j *JSON) Unmarshal(raw []byte, targetAddr interface{}) error {
-	return json.Unmarshal(raw, targetAddr)
+	if !j.DisallowUnknownFields && !j.UseNumber {
+		return json.Unmarshal(raw, targetAddr)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if j.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if j.UseNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(targetAddr); err != nil {
+		return err
+	}
+	// json.Decoder is meant for streams, so it doesn't complain about
+	// data following the first value, but json.Unmarshal does.
+	if err := dec.Decode(new(json.RawMessage)); err != io.EOF {
+		return errors.New("json: invalid data after top-level value")
+	}
+	return nil
+}
+
+// parseBool parses a boolean MIME parameter.  An empty value is
+// treated as true, since that means the parameter was present without
+// a value; invalid values return def.
+func parseBool(value string, def bool) bool {
+	if value == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// parseIndent parses an indent MIME parameter.  Invalid values return
+// def.
+func parseIndent(value, def string) string {
+	if strings.ToLower(value) == "tab" {
+		return "\t"
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 16 {
+		return def
+	}
+	return strings.Repeat(" ", n)
 }
@@ -2,7 +2,6 @@ package codecs_test
 
 import (
 	"encoding/json"
-	"net/http"
 
 	"github.com/nelsam/silverback"
 	"github.com/nelsam/silverback/codecs"
@@ -12,17 +11,11 @@ import (
 )
 
 var _ = Describe("Codecs", func() {
-	var (
-		codec silverback.Codec
-		req   *http.Request
-	)
+	var codec silverback.Codec
 
 	Context("json", func() {
 		BeforeEach(func() {
 			codec = &codecs.JSON{}
-			var err error
-			req, err = http.NewRequest("GET", "foo", nil)
-			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("returns a json codec when a new copy is requested", func() {
@@ -60,5 +53,62 @@ var _ = Describe("Codecs", func() {
 			Expect(codec.Unmarshal(raw, &actual)).ToNot(HaveOccurred())
 			Expect(actual).To(BeEquivalentTo(expected))
 		})
+
+		Context("Options", func() {
+			newCodec := func(mimeString string) silverback.Codec {
+				mime, _ := silverback.ParseMIMEType(mimeString)
+				return codec.New(mime)
+			}
+
+			It("does not modify the original codec", func() {
+				newCodec("application/json; pretty")
+				Expect(codec).To(Equal(&codecs.JSON{}))
+			})
+
+			It("uses the original codec's fields as defaults", func() {
+				codec = &codecs.JSON{Indent: "\t"}
+				Expect(newCodec("application/json").Marshal([]int{1})).To(BeEquivalentTo("[\n\t1\n]"))
+				Expect(newCodec("application/json; pretty=false").Marshal([]int{1})).To(BeEquivalentTo("[1]"))
+			})
+
+			It("indents output when requested", func() {
+				Expect(newCodec("application/json; pretty").Marshal([]int{1})).To(BeEquivalentTo("[\n  1\n]"))
+				Expect(newCodec("application/json; indent=4").Marshal([]int{1})).To(BeEquivalentTo("[\n    1\n]"))
+				Expect(newCodec("application/json; indent=tab").Marshal([]int{1})).To(BeEquivalentTo("[\n\t1\n]"))
+			})
+
+			It("toggles HTML escaping", func() {
+				Expect(newCodec("application/json").Marshal("<b>")).To(BeEquivalentTo(`"\u003cb\u003e"`))
+				Expect(newCodec("application/json; escape-html=false").Marshal("<b>")).To(BeEquivalentTo(`"<b>"`))
+			})
+
+			It("disallows unknown fields when requested", func() {
+				var target struct{ Foo string }
+				raw := []byte(`{"foo":"bar","baz":1}`)
+				Expect(newCodec("application/json").Unmarshal(raw, &target)).To(Succeed())
+				Expect(newCodec("application/json; disallow-unknown-fields").Unmarshal(raw, &target)).ToNot(Succeed())
+			})
+
+			It("decodes json.Number values when requested", func() {
+				var target map[string]interface{}
+				raw := []byte(`{"baz":12345678901234567890}`)
+				Expect(newCodec("application/json; use-number").Unmarshal(raw, &target)).To(Succeed())
+				Expect(target["baz"]).To(Equal(json.Number("12345678901234567890")))
+			})
+
+			It("errors from trailing data when using decoder options", func() {
+				var target map[string]interface{}
+				raw := []byte(`{"baz":1} {}`)
+				Expect(newCodec("application/json; use-number").Unmarshal(raw, &target)).ToNot(Succeed())
+			})
+
+			It("only matches the utf-8 charset", func() {
+				codecList := []silverback.Codec{codec}
+				utf8 := silverback.Accept{silverback.ParseAcceptEntry("application/json; charset=UTF-8")}
+				Expect(utf8.Codec(codecList)).ToNot(BeNil())
+				latin1 := silverback.Accept{silverback.ParseAcceptEntry("application/json; charset=iso-8859-1")}
+				Expect(latin1.Codec(codecList)).To(BeNil())
+			})
+		})
 	})
 })
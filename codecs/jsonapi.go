@@ -0,0 +1,399 @@
+package codecs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nelsam/silverback"
+)
+
+// A JSONAPITyped value chooses its own JSON:API resource type.  Values
+// that don't implement it use the name of the resource they were
+// routed to (the last element of its Path()).
+type JSONAPITyped interface {
+	ResourceType() string
+}
+
+// A JSONAPIRelator value has relationships to other resources.  Each
+// value in the returned map is the related value, a slice of related
+// values, or nil; related values must implement silverback.Identified.
+// Related values that don't implement JSONAPITyped use the
+// relationship's name as their type.
+type JSONAPIRelator interface {
+	Relationships() map[string]interface{}
+}
+
+// JSONAPI is a codec that handles JSON:API (application/vnd.api+json)
+// documents.  Bodies are rendered as resource objects, with:
+//
+//   - type from JSONAPITyped, or the routed resource's name.
+//   - id from silverback.Identified, or the routed resource's id.
+//   - attributes from the body's JSON representation, excluding its
+//     "id" member and any relationships.
+//   - relationships from JSONAPIRelator.
+//
+// Related resources are added to the document's "included" member
+// when requested using the include query parameter, and attributes
+// are limited by fields[type] query parameters (sparse fieldsets).
+// Problems are rendered as JSON:API error objects.
+//
+// As required by the JSON:API spec, the codec only matches MIME types
+// whose parameters are limited to ext and profile; with a Router,
+// this results in 415 responses for other Content-Type parameters and
+// 406 responses when every JSON:API entry in the Accept header has
+// other parameters.
+type JSONAPI struct {
+	JSON JSON
+
+	resource *silverback.Resource
+	query    url.Values
+}
+
+// jsonAPIResource is a JSON:API resource object.
+type jsonAPIResource struct {
+	Type          string                     `json:"type"`
+	ID            string                     `json:"id,omitempty"`
+	Attributes    map[string]json.RawMessage `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelation `json:"relationships,omitempty"`
+	Links         map[string]string          `json:"links,omitempty"`
+}
+
+// jsonAPIIdentifier is a JSON:API resource identifier object.
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type jsonAPIRelation struct {
+	Data interface{} `json:"data"`
+}
+
+type jsonAPIError struct {
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// New returns a copy of j, configured using the parameters of
+// matched.
+func (j *JSONAPI) New(matched silverback.MIMEType) silverback.Codec {
+	return &JSONAPI{
+		JSON: *j.JSON.New(matched).(*JSON),
+	}
+}
+
+// ForRequest returns a copy of j that uses the resource that r was
+// routed to and r's include and fields query parameters.
+func (j *JSONAPI) ForRequest(r *http.Request) silverback.Codec {
+	c := *j
+	if resource, ok := silverback.RequestResource(r); ok {
+		c.resource = &resource
+	}
+	c.query = r.URL.Query()
+	return &c
+}
+
+// Match returns false if requested has any parameters other than ext
+// and profile.
+func (j *JSONAPI) Match(requested silverback.MIMEType) bool {
+	for name := range requested.Options {
+		if name != "ext" && name != "profile" {
+			return false
+		}
+	}
+	return true
+}
+
+// Types returns the MIME types that this codec is capable of handling.
+func (j *JSONAPI) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "application",
+			SubType: "vnd.api+json",
+		},
+	}
+}
+
+// MarshalProblem marshals p as a JSON:API error document.
+func (j *JSONAPI) MarshalProblem(p *silverback.Problem) ([]byte, error) {
+	apiErr := jsonAPIError{
+		Code:   p.Type,
+		Title:  p.Title,
+		Detail: p.Detail,
+		Meta:   p.Extensions,
+	}
+	if p.Status != 0 {
+		apiErr.Status = strconv.Itoa(p.Status)
+	}
+	return j.JSON.Marshal(map[string]interface{}{
+		"errors": []jsonAPIError{apiErr},
+	})
+}
+
+// Marshal marshals target to a JSON:API document, returning the bytes
+// and any errors encountered.  If the request asks to include an
+// unknown relationship, the returned error is a 400 Bad Request
+// *silverback.Problem.
+func (j *JSONAPI) Marshal(target interface{}) ([]byte, error) {
+	if p, ok := target.(*silverback.Problem); ok {
+		return j.MarshalProblem(p)
+	}
+	doc := map[string]interface{}{}
+	var primary []interface{}
+	v := reflect.ValueOf(target)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		data := make([]jsonAPIResource, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i).Interface()
+			res, err := j.resourceObject(item, j.routedType(), "")
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, res)
+			primary = append(primary, item)
+		}
+		doc["data"] = data
+	} else if target == nil {
+		doc["data"] = nil
+	} else {
+		res, err := j.resourceObject(target, j.routedType(), j.routedID())
+		if err != nil {
+			return nil, err
+		}
+		doc["data"] = res
+		primary = append(primary, target)
+	}
+	included, err := j.included(primary)
+	if err != nil {
+		return nil, err
+	}
+	if len(included) > 0 {
+		doc["included"] = included
+	}
+	return j.JSON.Marshal(doc)
+}
+
+// included returns the resource objects for the relationships of
+// primary that were requested using the include query parameter.
+func (j *JSONAPI) included(primary []interface{}) ([]jsonAPIResource, error) {
+	include := j.query.Get("include")
+	if include == "" {
+		return nil, nil
+	}
+	var included []jsonAPIResource
+	seen := map[jsonAPIIdentifier]bool{}
+	for _, rel := range strings.Split(include, ",") {
+		rel = strings.TrimSpace(rel)
+		found := false
+		for _, item := range primary {
+			relator, ok := item.(JSONAPIRelator)
+			if !ok {
+				continue
+			}
+			related, ok := relator.Relationships()[rel]
+			if !ok {
+				continue
+			}
+			found = true
+			for _, value := range relatedValues(related) {
+				res, err := j.resourceObject(value, rel, "")
+				if err != nil {
+					return nil, err
+				}
+				key := jsonAPIIdentifier{Type: res.Type, ID: res.ID}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				included = append(included, res)
+			}
+		}
+		if !found && len(primary) > 0 {
+			return nil, silverback.NewProblem(http.StatusBadRequest, "Unknown relationship path for include: "+rel)
+		}
+	}
+	return included, nil
+}
+
+// resourceObject builds the resource object for value.  If value
+// doesn't implement JSONAPITyped, typ is used as its type; if it
+// doesn't implement silverback.Identified, id is used as its id.
+func (j *JSONAPI) resourceObject(value interface{}, typ, id string) (jsonAPIResource, error) {
+	res := jsonAPIResource{
+		Type: typ,
+		ID:   id,
+	}
+	if typed, ok := value.(JSONAPITyped); ok {
+		res.Type = typed.ResourceType()
+	}
+	if identified, ok := value.(silverback.Identified); ok {
+		res.ID = identified.ID()
+	}
+	raw, err := j.JSON.Marshal(value)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(raw, &res.Attributes); err != nil {
+		return res, errors.New("jsonapi: resources must be marshalled as JSON objects")
+	}
+	delete(res.Attributes, "id")
+	if relator, ok := value.(JSONAPIRelator); ok {
+		res.Relationships = make(map[string]jsonAPIRelation)
+		for name, related := range relator.Relationships() {
+			delete(res.Attributes, name)
+			res.Relationships[name] = jsonAPIRelation{Data: linkage(name, related)}
+		}
+	}
+	j.applyFieldset(&res)
+	if j.resource != nil && j.resource.ItemRoute && res.ID != "" && res.Type == j.routedType() {
+		res.Links = map[string]string{"self": j.resource.ItemPath(res.ID)}
+	}
+	return res, nil
+}
+
+// applyFieldset removes any attributes and relationships from res that
+// weren't requested in a fields[type] query parameter for its type.
+func (j *JSONAPI) applyFieldset(res *jsonAPIResource) {
+	fields, ok := j.query["fields["+res.Type+"]"]
+	if !ok {
+		return
+	}
+	allowed := map[string]bool{}
+	for _, list := range fields {
+		for _, field := range strings.Split(list, ",") {
+			allowed[strings.TrimSpace(field)] = true
+		}
+	}
+	for name := range res.Attributes {
+		if !allowed[name] {
+			delete(res.Attributes, name)
+		}
+	}
+	for name := range res.Relationships {
+		if !allowed[name] {
+			delete(res.Relationships, name)
+		}
+	}
+}
+
+func (j *JSONAPI) routedType() string {
+	if j.resource == nil {
+		return ""
+	}
+	return path.Base(j.resource.Path)
+}
+
+func (j *JSONAPI) routedID() string {
+	if j.resource == nil {
+		return ""
+	}
+	return j.resource.ID
+}
+
+// linkage returns the resource linkage for a relationship.
+func linkage(name string, related interface{}) interface{} {
+	if related == nil {
+		return nil
+	}
+	v := reflect.ValueOf(related)
+	toMany := v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+	identifiers := make([]jsonAPIIdentifier, 0)
+	for _, value := range relatedValues(related) {
+		identifier := jsonAPIIdentifier{Type: name}
+		if typed, ok := value.(JSONAPITyped); ok {
+			identifier.Type = typed.ResourceType()
+		}
+		if identified, ok := value.(silverback.Identified); ok {
+			identifier.ID = identified.ID()
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	if toMany {
+		return identifiers
+	}
+	if len(identifiers) == 0 {
+		return nil
+	}
+	return identifiers[0]
+}
+
+// relatedValues returns the values in a relationship, which may be a
+// single value or a slice of values.
+func relatedValues(related interface{}) []interface{} {
+	if related == nil {
+		return nil
+	}
+	v := reflect.ValueOf(related)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		return []interface{}{related}
+	}
+	values := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		values = append(values, v.Index(i).Interface())
+	}
+	return values
+}
+
+// Unmarshal unmarshals a JSON:API document to the value that is
+// pointed to by targetAddr.  The primary data's attributes and id are
+// unmarshalled as though they were members of a single JSON object;
+// relationships are ignored.
+func (j *JSONAPI) Unmarshal(raw []byte, targetAddr interface{}) error {
+	var doc struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	if len(doc.Data) == 0 {
+		return errors.New("jsonapi: document has no primary data")
+	}
+	var flattened interface{}
+	if doc.Data[0] == '[' {
+		var resources []jsonAPIResource
+		if err := json.Unmarshal(doc.Data, &resources); err != nil {
+			return err
+		}
+		objects := make([]map[string]json.RawMessage, 0, len(resources))
+		for _, res := range resources {
+			objects = append(objects, flatten(res))
+		}
+		flattened = objects
+	} else {
+		var res jsonAPIResource
+		if err := json.Unmarshal(doc.Data, &res); err != nil {
+			return err
+		}
+		flattened = flatten(res)
+	}
+	flat, err := json.Marshal(flattened)
+	if err != nil {
+		return err
+	}
+	return j.JSON.Unmarshal(flat, targetAddr)
+}
+
+// flatten combines the id and attributes of res into a single JSON
+// object.
+func flatten(res jsonAPIResource) map[string]json.RawMessage {
+	object := make(map[string]json.RawMessage, len(res.Attributes)+1)
+	for name, value := range res.Attributes {
+		object[name] = value
+	}
+	if res.ID != "" {
+		id, _ := json.Marshal(res.ID)
+		object["id"] = id
+	}
+	return object
+}
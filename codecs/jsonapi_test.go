@@ -0,0 +1,176 @@
+package codecs_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type person struct {
+	PersonID string `json:"id"`
+	Name     string `json:"name"`
+}
+
+func (p person) ID() string {
+	return p.PersonID
+}
+
+func (p person) ResourceType() string {
+	return "people"
+}
+
+type article struct {
+	ArticleID string  `json:"id"`
+	Title     string  `json:"title"`
+	Body      string  `json:"body"`
+	Author    *person `json:"author,omitempty"`
+}
+
+func (a article) ID() string {
+	return a.ArticleID
+}
+
+func (a article) Relationships() map[string]interface{} {
+	return map[string]interface{}{
+		"author": a.Author,
+	}
+}
+
+type articleHandler struct {
+	req    *http.Request
+	body   article
+	posted chan article
+}
+
+func (h *articleHandler) New(r *http.Request) silverback.Handler {
+	return &articleHandler{req: r, posted: h.posted}
+}
+
+func (h *articleHandler) Path() string {
+	return "/articles"
+}
+
+func (h *articleHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = article{
+		ArticleID: id,
+		Title:     "Rails is Omakase",
+		Body:      "...",
+		Author:    &person{PersonID: "9", Name: "Dan"},
+	}
+	return resp
+}
+
+func (h *articleHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *articleHandler) Post() *silverback.Response {
+	h.posted <- h.body
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.body
+	return resp
+}
+
+var _ = Describe("JSONAPI", func() {
+	var (
+		router   *silverback.Router
+		articles *articleHandler
+	)
+
+	serve := func(method, path, contentType, accept string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		Expect(err).ToNot(HaveOccurred())
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		articles = &articleHandler{posted: make(chan article, 1)}
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSONAPI{})
+		router.Route(articles)
+	})
+
+	It("renders resource objects with relationships", func() {
+		recorder := serve("GET", "/articles/1", "", "application/vnd.api+json", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/vnd.api+json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"data": {
+				"type": "articles",
+				"id": "1",
+				"attributes": {"title": "Rails is Omakase", "body": "..."},
+				"relationships": {
+					"author": {"data": {"type": "people", "id": "9"}}
+				},
+				"links": {"self": "/articles/1"}
+			}
+		}`))
+	})
+
+	It("includes related resources and applies sparse fieldsets", func() {
+		recorder := serve("GET", "/articles/1?include=author&fields[articles]=title,author", "", "application/vnd.api+json", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"data": {
+				"type": "articles",
+				"id": "1",
+				"attributes": {"title": "Rails is Omakase"},
+				"relationships": {
+					"author": {"data": {"type": "people", "id": "9"}}
+				},
+				"links": {"self": "/articles/1"}
+			},
+			"included": [
+				{"type": "people", "id": "9", "attributes": {"name": "Dan"}}
+			]
+		}`))
+	})
+
+	It("responds with a 400 error object for unknown include paths", func() {
+		recorder := serve("GET", "/articles/1?include=comments", "", "application/vnd.api+json", "")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/vnd.api+json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"errors": [{
+				"status": "400",
+				"title": "Bad Request",
+				"detail": "Unknown relationship path for include: comments"
+			}]
+		}`))
+	})
+
+	It("responds with 406 when every JSON:API Accept entry has parameters", func() {
+		recorder := serve("GET", "/articles/1", "", "application/vnd.api+json; charset=utf-8", "")
+		Expect(recorder.Code).To(Equal(http.StatusNotAcceptable))
+
+		recorder = serve("GET", "/articles/1", "", "application/vnd.api+json; charset=utf-8, application/vnd.api+json; ext=foo", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("decodes request bodies", func() {
+		body := `{"data": {"type": "articles", "attributes": {"title": "Ember Hamster"}}}`
+		recorder := serve("POST", "/articles", "application/vnd.api+json", "application/vnd.api+json", body)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(articles.posted).To(Receive(Equal(article{Title: "Ember Hamster"})))
+	})
+
+	It("responds with 415 for Content-Type parameters", func() {
+		body := `{"data": {"type": "articles", "attributes": {"title": "Ember Hamster"}}}`
+		recorder := serve("POST", "/articles", "application/vnd.api+json; charset=utf-8", "application/vnd.api+json", body)
+		Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(articles.posted).ToNot(Receive())
+	})
+})
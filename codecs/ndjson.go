@@ -0,0 +1,118 @@
+package codecs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+
+	"github.com/nelsam/silverback"
+)
+
+// NDJSON is a codec that handles newline delimited JSON, where each
+// line is a separate JSON document.  It is a silverback.StreamCodec,
+// so streamed response bodies (see silverback.Response) are written
+// one line at a time as items are produced.
+//
+// Non-streamed slices and arrays are marshalled one element per line,
+// and any other value is marshalled as a single line.  Unmarshal
+// requires a pointer to a slice, and appends one element per line.
+//
+// The fields on an NDJSON value act as defaults, and may be
+// overridden by MIME type parameters in the same way as the JSON
+// codec's fields.  Indentation is not supported, since it would break
+// the line-delimited format.
+type NDJSON struct {
+	DisableHTMLEscaping   bool
+	DisallowUnknownFields bool
+	UseNumber             bool
+}
+
+// New returns a copy of n, configured using the parameters of
+// matched.
+func (n *NDJSON) New(matched silverback.MIMEType) silverback.Codec {
+	j := n.json().New(matched).(*JSON)
+	return &NDJSON{
+		DisableHTMLEscaping:   j.DisableHTMLEscaping,
+		DisallowUnknownFields: j.DisallowUnknownFields,
+		UseNumber:             j.UseNumber,
+	}
+}
+
+// Match returns false if requested has a charset parameter that isn't
+// UTF-8.
+func (n *NDJSON) Match(requested silverback.MIMEType) bool {
+	return n.json().Match(requested)
+}
+
+// Types returns the MIME types that this codec is capable of handling.
+func (n *NDJSON) Types() []silverback.MIMEType {
+	return []silverback.MIMEType{
+		{
+			Type:    "application",
+			SubType: "x-ndjson",
+		},
+	}
+}
+
+// MarshalItem marshals a single item to a line of JSON, including
+// the trailing newline.
+func (n *NDJSON) MarshalItem(item interface{}) ([]byte, error) {
+	line, err := n.json().Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// Marshal marshals target to newline delimited JSON, returning the
+// bytes and any errors encountered.
+func (n *NDJSON) Marshal(target interface{}) ([]byte, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return n.MarshalItem(target)
+	}
+	var buf bytes.Buffer
+	for i := 0; i < v.Len(); i++ {
+		line, err := n.MarshalItem(v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal unmarshals newline delimited JSON to the slice that is
+// pointed to by targetAddr, appending one element for each non-empty
+// line.  It returns any errors encountered.
+func (n *NDJSON) Unmarshal(raw []byte, targetAddr interface{}) error {
+	v := reflect.ValueOf(targetAddr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("ndjson: Unmarshal requires a pointer to a slice")
+	}
+	slice := v.Elem()
+	j := n.json()
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, len(raw)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		item := reflect.New(slice.Type().Elem())
+		if err := j.Unmarshal(line, item.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+	return scanner.Err()
+}
+
+func (n *NDJSON) json() *JSON {
+	return &JSON{
+		DisableHTMLEscaping:   n.DisableHTMLEscaping,
+		DisallowUnknownFields: n.DisallowUnknownFields,
+		UseNumber:             n.UseNumber,
+	}
+}
@@ -0,0 +1,54 @@
+package codecs_test
+
+import (
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NDJSON", func() {
+	var codec silverback.Codec
+
+	BeforeEach(func() {
+		codec = &codecs.NDJSON{}
+	})
+
+	It("is a stream codec", func() {
+		_, isStream := codec.(silverback.StreamCodec)
+		Expect(isStream).To(BeTrue())
+	})
+
+	It("supports the application/x-ndjson MIME type", func() {
+		ndjson, _ := silverback.ParseMIMEType("application/x-ndjson")
+		Expect(codec.Types()).To(ConsistOf(ndjson))
+	})
+
+	It("marshals items to single lines", func() {
+		stream := codec.(silverback.StreamCodec)
+		Expect(stream.MarshalItem(map[string]int{"foo": 1})).To(BeEquivalentTo("{\"foo\":1}\n"))
+	})
+
+	It("ignores indentation options", func() {
+		mime, _ := silverback.ParseMIMEType("application/x-ndjson; pretty")
+		stream := codec.New(mime).(silverback.StreamCodec)
+		Expect(stream.MarshalItem([]int{1})).To(BeEquivalentTo("[1]\n"))
+	})
+
+	It("marshals slices one element per line", func() {
+		Expect(codec.Marshal([]int{1, 2, 3})).To(BeEquivalentTo("1\n2\n3\n"))
+	})
+
+	It("unmarshals one element per line", func() {
+		var actual []map[string]int
+		raw := []byte("{\"foo\":1}\n\n{\"foo\":2}\n")
+		Expect(codec.Unmarshal(raw, &actual)).To(Succeed())
+		Expect(actual).To(Equal([]map[string]int{{"foo": 1}, {"foo": 2}}))
+	})
+
+	It("requires a slice to unmarshal to", func() {
+		var actual map[string]int
+		Expect(codec.Unmarshal([]byte("{\"foo\":1}\n"), &actual)).ToNot(Succeed())
+	})
+})
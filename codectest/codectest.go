@@ -0,0 +1,297 @@
+// Package codectest provides a conformance suite for implementations
+// of silverback.Codec.  It checks that a codec behaves the way that a
+// silverback.Router expects it to:
+//
+//   - Types() returns at least one MIME type, and each of them
+//     survives a round trip through MIMEType.String and
+//     silverback.ParseMIMEType.
+//   - New returns a usable codec for each of its Types(), and Matcher
+//     codecs match their own Types().
+//   - New, Marshal, and Unmarshal are safe to call from multiple
+//     goroutines, since a Router shares codecs between requests.
+//   - Marshal and Unmarshal are symmetric, using randomly generated
+//     values (see testing/quick) of each sample type.
+//   - Unmarshal returns errors, rather than panicking, when given
+//     arbitrary bytes.
+//
+// A typical use, in a codec's tests, looks like:
+//
+//	func TestConformance(t *testing.T) {
+//		codectest.Suite{
+//			Codec:   &MyCodec{},
+//			Samples: []interface{}{myType{}},
+//		}.Run(t)
+//	}
+package codectest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/nelsam/silverback"
+)
+
+const (
+	defaultCount       = 100
+	defaultConcurrency = 8
+)
+
+// Suite is a conformance suite for a silverback.Codec.
+type Suite struct {
+	// Codec is the codec under test, as it would be passed to
+	// Router.AddCodec.
+	Codec silverback.Codec
+
+	// Samples are values whose types are used to generate values for
+	// the round trip checks.  Generated values are built by
+	// testing/quick, so sample types must only have exported fields,
+	// and must not contain interfaces, channels, or functions.
+	// Samples may also be pointers to such types.
+	//
+	// If Samples is empty, the round trip checks are skipped.
+	Samples []interface{}
+
+	// Count is the number of values generated per sample for the
+	// round trip checks.  It defaults to 100.
+	Count int
+
+	// Concurrency is the number of goroutines used for the
+	// concurrency checks.  It defaults to 8.
+	Concurrency int
+
+	// Rand is the source of randomness for generated values.  It
+	// defaults to a source seeded with 1, so that failures can be
+	// reproduced.
+	Rand *rand.Rand
+
+	// Equal reports whether a value that was unmarshalled (actual)
+	// is equivalent to the value that was marshalled (expected).  It
+	// defaults to reflect.DeepEqual; codecs that can't preserve every
+	// detail of a value (such as the difference between nil and empty
+	// slices) may provide a more forgiving comparison.
+	Equal func(expected, actual interface{}) bool
+}
+
+// Run runs every check in s as subtests of t.
+func (s Suite) Run(t *testing.T) {
+	if s.Codec == nil {
+		t.Fatal("codectest: Suite.Codec is nil")
+	}
+	t.Run("Types", s.TestTypes)
+	t.Run("New", s.TestNew)
+	t.Run("Concurrency", s.TestConcurrency)
+	t.Run("RoundTrip", s.TestRoundTrip)
+	t.Run("UnmarshalGarbage", s.TestUnmarshalGarbage)
+}
+
+// TestTypes checks that s.Codec supports at least one MIME type, and
+// that each of its Types() can be parsed from its own String().
+func (s Suite) TestTypes(t *testing.T) {
+	types := s.Codec.Types()
+	if len(types) == 0 {
+		t.Fatal("Types() returned no MIME types")
+	}
+	for _, typ := range types {
+		if typ.Type == "" || typ.SubType == "" {
+			t.Errorf("Types() returned %#v, which is missing a type or sub-type", typ)
+			continue
+		}
+		parsed, acceptOptions := silverback.ParseMIMEType(typ.String())
+		if len(acceptOptions) > 0 {
+			t.Errorf("Types() returned %q, which has Accept options (such as q)", typ)
+		}
+		if !sameType(typ, parsed) {
+			t.Errorf("Types() returned %#v, but parsing %q returned %#v", typ, typ, parsed)
+		}
+	}
+}
+
+// TestNew checks that New returns a non-nil codec for each of
+// s.Codec's Types(), and that Matcher codecs match their own Types().
+func (s Suite) TestNew(t *testing.T) {
+	for _, typ := range s.Codec.Types() {
+		if matcher, ok := s.Codec.(silverback.Matcher); ok && !matcher.Match(typ) {
+			t.Errorf("Match(%q) returned false for one of the codec's own Types()", typ)
+		}
+		if codec := s.Codec.New(typ); codec == nil {
+			t.Errorf("New(%q) returned nil", typ)
+		}
+	}
+}
+
+// TestConcurrency calls New, Marshal, and Unmarshal on s.Codec from
+// several goroutines at once.  It is most useful when tests are run
+// with the race detector enabled.
+func (s Suite) TestConcurrency(t *testing.T) {
+	types := s.Codec.Types()
+	if len(types) == 0 {
+		t.Skip("Types() returned no MIME types")
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, s.concurrency())
+	for i := 0; i < s.concurrency(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs <- fmt.Errorf("goroutine %d panicked: %v", i, r)
+				}
+			}()
+			typ := types[i%len(types)]
+			codec := s.Codec.New(typ)
+			if codec == nil {
+				errs <- fmt.Errorf("New(%q) returned nil", typ)
+				return
+			}
+			for _, sample := range s.Samples {
+				raw, err := s.Codec.Marshal(sample)
+				if err != nil {
+					errs <- fmt.Errorf("Marshal(%#v) failed: %v", sample, err)
+					return
+				}
+				target := reflect.New(reflect.TypeOf(sample))
+				if err := s.Codec.Unmarshal(raw, target.Interface()); err != nil {
+					errs <- fmt.Errorf("Unmarshal(%q) failed: %v", raw, err)
+					return
+				}
+				if _, err := codec.Marshal(sample); err != nil {
+					errs <- fmt.Errorf("New(%q).Marshal(%#v) failed: %v", typ, sample, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestRoundTrip checks that values of each of s.Samples' types are
+// equal after being marshalled and unmarshalled, using each of
+// s.Codec's Types().
+func (s Suite) TestRoundTrip(t *testing.T) {
+	if len(s.Samples) == 0 {
+		t.Skip("no Samples to generate values from")
+	}
+	for _, typ := range s.Codec.Types() {
+		codec := s.Codec.New(typ)
+		for _, sample := range s.Samples {
+			sampleType := reflect.TypeOf(sample)
+			t.Run(fmt.Sprintf("%s/%s", typ, sampleType), func(t *testing.T) {
+				check := func(value interface{}) bool {
+					return s.roundTrip(t, codec, value)
+				}
+				if !check(sample) {
+					return
+				}
+				config := &quick.Config{
+					MaxCount: s.count(),
+					Rand:     s.rand(),
+					Values: func(args []reflect.Value, r *rand.Rand) {
+						v, ok := quick.Value(sampleType, r)
+						if !ok {
+							t.Fatalf("testing/quick can't generate values of type %s", sampleType)
+						}
+						args[0] = v
+					},
+				}
+				if err := quick.Check(check, config); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+// roundTrip marshals value using codec, then unmarshals it into a new
+// value of the same type, reporting any differences to t.
+func (s Suite) roundTrip(t *testing.T, codec silverback.Codec, value interface{}) bool {
+	raw, err := codec.Marshal(value)
+	if err != nil {
+		t.Errorf("Marshal(%#v) failed: %v", value, err)
+		return false
+	}
+	target := reflect.New(reflect.TypeOf(value))
+	if err := codec.Unmarshal(raw, target.Interface()); err != nil {
+		t.Errorf("Unmarshal(%q) failed: %v", raw, err)
+		return false
+	}
+	actual := target.Elem().Interface()
+	if !s.equal(value, actual) {
+		t.Errorf("round trip of %#v through %q returned %#v", value, raw, actual)
+		return false
+	}
+	return true
+}
+
+// TestUnmarshalGarbage checks that Unmarshal returns (rather than
+// panics) when given random bytes.  Whether or not it returns an
+// error is up to the codec.
+func (s Suite) TestUnmarshalGarbage(t *testing.T) {
+	targetType := reflect.TypeOf(map[string]interface{}{})
+	if len(s.Samples) > 0 {
+		targetType = reflect.TypeOf(s.Samples[0])
+	}
+	r := s.rand()
+	for i := 0; i < s.count(); i++ {
+		raw := make([]byte, r.Intn(64))
+		r.Read(raw)
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Errorf("Unmarshal(%q) panicked: %v", raw, p)
+				}
+			}()
+			s.Codec.Unmarshal(raw, reflect.New(targetType).Interface())
+		}()
+	}
+}
+
+func (s Suite) count() int {
+	if s.Count > 0 {
+		return s.Count
+	}
+	return defaultCount
+}
+
+func (s Suite) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return defaultConcurrency
+}
+
+func (s Suite) rand() *rand.Rand {
+	if s.Rand != nil {
+		return s.Rand
+	}
+	return rand.New(rand.NewSource(1))
+}
+
+func (s Suite) equal(expected, actual interface{}) bool {
+	if s.Equal != nil {
+		return s.Equal(expected, actual)
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+// sameType returns whether or not a and b are the same MIME type,
+// treating nil and empty Options as equal.
+func sameType(a, b silverback.MIMEType) bool {
+	if a.Type != b.Type || a.SubType != b.SubType || len(a.Options) != len(b.Options) {
+		return false
+	}
+	for name, value := range a.Options {
+		if other, ok := b.Options[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
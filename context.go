@@ -0,0 +1,215 @@
+package silverback
+
+import (
+	"context"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey int
+
+const (
+	resourceKey contextKey = iota
+	httpRequestKey
+	principalKey
+	codecKey
+	languageKey
+)
+
+// A Resource describes the resource and handler method that a
+// request was routed to.
+type Resource struct {
+	// Path is the Handler.Path() of the resource.  For resources
+	// routed by a child router, it is nested under the item path of
+	// the parent instance (e.g. /users/42/posts).
+	Path string
+
+	// Method is the name of the handler method that is handling the
+	// request (e.g. "Get" or "Query").  It is "Action" for requests
+	// to an Actioner's actions.
+	Method string
+
+	// Action is the name of the action that is handling the request,
+	// for requests to an Actioner's actions.
+	Action string
+
+	// ID is the identifier of the resource instance that the request
+	// is for.  It is empty for requests to the collection.
+	ID string
+
+	// Key is the structured form of ID (see IDTemplater).  It is nil
+	// for requests to the collection.
+	Key Key
+
+	// ItemRoute is whether or not individual instances of the
+	// resource can be requested (i.e. whether the handler is a
+	// Getter).
+	ItemRoute bool
+
+	// Links holds any links added by the handler, if it is a Linker.
+	// It is only populated after the handler method has returned.
+	Links []Link
+
+	// Parents holds the parent resources of a resource routed by a
+	// child router (see Router.Sub), outermost first.  Each parent's
+	// Path is its collection path and its ID identifies the instance
+	// that this resource is nested under.
+	Parents []Resource
+
+	// Handler is the handler that was passed to Router.Route.  The
+	// handler handling the request was returned by its New method.
+	Handler Handler
+
+	// router is the Router that routed the request, for URLFor.
+	router *Router
+}
+
+// ParentID returns the identifier of the resource's immediate parent,
+// or an empty string if the resource has no parents.
+func (r Resource) ParentID() string {
+	if len(r.Parents) == 0 {
+		return ""
+	}
+	return r.Parents[len(r.Parents)-1].ID
+}
+
+// CollectionPath returns the path of the resource's collection.
+func (r Resource) CollectionPath() string {
+	return r.Path
+}
+
+// ItemPath returns the path of the resource instance identified by
+// id.
+func (r Resource) ItemPath(id string) string {
+	return path.Join(r.Path, id)
+}
+
+// RequestResource returns the Resource that r was routed to by a
+// Router.  If r was not routed by a Router, ok will be false.
+func RequestResource(r *http.Request) (resource Resource, ok bool) {
+	res := requestResource(r)
+	if res == nil {
+		return Resource{}, false
+	}
+	return *res, true
+}
+
+// requestResource returns the *Resource attached to r, so that the
+// router can update it while handling r.
+func requestResource(r *http.Request) *Resource {
+	resource, _ := r.Context().Value(resourceKey).(*Resource)
+	return resource
+}
+
+// withResource returns a shallow copy of r with resource attached to
+// its context.
+func withResource(r *http.Request, resource *Resource) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), resourceKey, resource))
+}
+
+// A ContextHandler is a Handler that is constructed from the context
+// of a request, rather than the request itself.  When a handler is a
+// ContextHandler, the Router calls NewContext instead of New for each
+// request.  The context carries the values attached by the Router (see
+// RequestFromContext, ResourceFromContext, RouteVars, ResponseCodec,
+// and Language) along with any attached by middleware (such as
+// WithPrincipal).
+//
+// The context is done when the client disconnects (or when a deadline
+// set by middleware passes), so handlers doing long-running work
+// should watch ctx.Done() and return early.  If the context was
+// canceled, the Router discards the handler's response instead of
+// writing it; if its deadline passed, the Router responds with a 503
+// Service Unavailable problem instead.
+type ContextHandler interface {
+	Handler
+	NewContext(ctx context.Context) Handler
+}
+
+// newHandler returns the copy of handler that will handle req.
+func (r *Router) newHandler(handler Handler, req *http.Request) Handler {
+	contextHandler, ok := handler.(ContextHandler)
+	if !ok {
+		return handler.New(req)
+	}
+	return contextHandler.NewContext(r.requestContext(req))
+}
+
+// requestContext returns req's context, with the request, its
+// negotiated codec, and its language attached.
+func (r *Router) requestContext(req *http.Request) context.Context {
+	ctx := context.WithValue(req.Context(), httpRequestKey, req)
+	codec, mime := negotiateCodec(req, r.codecs())
+	ctx = context.WithValue(ctx, codecKey, negotiatedCodec{codec: codec, mime: mime})
+	return context.WithValue(ctx, languageKey, negotiateLanguage(req.Header.Get("Accept-Language"), r.languages()))
+}
+
+// negotiatedCodec is the codec and MIME type chosen for a response.
+type negotiatedCodec struct {
+	codec Codec
+	mime  MIMEType
+}
+
+// RequestFromContext returns the request that ctx was passed to a
+// ContextHandler (or a typed handler method, such as TypedGetter.Get)
+// for, or nil if ctx doesn't belong to a request.
+func RequestFromContext(ctx context.Context) *http.Request {
+	req, _ := ctx.Value(httpRequestKey).(*http.Request)
+	return req
+}
+
+// ResourceFromContext returns the Resource that the request owning ctx
+// was routed to.  See RequestResource.
+func ResourceFromContext(ctx context.Context) (resource Resource, ok bool) {
+	res, _ := ctx.Value(resourceKey).(*Resource)
+	if res == nil {
+		return Resource{}, false
+	}
+	return *res, true
+}
+
+// RouteVars returns the gorilla/mux route variables of the request
+// that ctx was passed to a ContextHandler for.
+func RouteVars(ctx context.Context) map[string]string {
+	req := RequestFromContext(ctx)
+	if req == nil {
+		return nil
+	}
+	return mux.Vars(req)
+}
+
+// ResponseCodec returns the codec that the Router chose for responses
+// to the request that ctx was passed to a ContextHandler for, based on
+// its Accept header, along with the matching MIME type.  It is the
+// codec that Response.Codec chooses, so a request without an Accept
+// header accepts any codec.  The codec is nil if none of the Router's
+// codecs are acceptable.
+func ResponseCodec(ctx context.Context) (Codec, MIMEType) {
+	negotiated, _ := ctx.Value(codecKey).(negotiatedCodec)
+	return negotiated.codec, negotiated.mime
+}
+
+// Language returns the language that the Router chose for the request
+// that ctx was passed to a ContextHandler for (see
+// Router.SetLanguages).  It is empty if the Router has no languages.
+func Language(ctx context.Context) string {
+	language, _ := ctx.Value(languageKey).(string)
+	return language
+}
+
+// WithPrincipal returns a copy of ctx carrying principal, the
+// authenticated caller of a request.  It is intended for middleware
+// (see Middleware), e.g.:
+//
+//	next.ServeHTTP(w, r.WithContext(silverback.WithPrincipal(r.Context(), user)))
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// Principal returns the principal attached to ctx by WithPrincipal,
+// or nil if there is none.
+func Principal(ctx context.Context) interface{} {
+	return ctx.Value(principalKey)
+}
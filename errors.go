@@ -0,0 +1,277 @@
+package silverback
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Errors that the default ErrorMapper maps to problems with matching
+// statuses.  Handlers may return them (or errors wrapping them) from
+// any method that returns an error.
+var (
+	// ErrNotFound results in a 404 Not Found problem.
+	ErrNotFound = errors.New("silverback: resource not found")
+
+	// ErrConflict results in a 409 Conflict problem.
+	ErrConflict = errors.New("silverback: resource conflict")
+)
+
+// An InvalidParam describes a single member of a request that failed
+// validation, as in the "invalid-params" extension from the examples
+// in RFC 9457.
+type InvalidParam struct {
+	Name   string `json:"name" xml:"name"`
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// A ValidationError is an error reporting that a request body or
+// query failed validation.  The default ErrorMapper maps it to a 422
+// Unprocessable Content problem, with Params as the problem's
+// "invalid-params" extension member.
+type ValidationError struct {
+	Params []InvalidParam
+}
+
+// Invalid returns a *ValidationError for a single invalid member.
+func Invalid(name, reason string) *ValidationError {
+	return &ValidationError{Params: []InvalidParam{{Name: name, Reason: reason}}}
+}
+
+// Add adds an invalid member to e.
+func (e *ValidationError) Add(name, reason string) {
+	e.Params = append(e.Params, InvalidParam{Name: name, Reason: reason})
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Params))
+	for _, param := range e.Params {
+		reasons = append(reasons, param.Name+": "+param.Reason)
+	}
+	return "validation failed: " + strings.Join(reasons, "; ")
+}
+
+// An ErrorMapper converts errors returned by handlers (from handler
+// methods, BeforeHandle, AfterHandle, and typed handler methods), and
+// errors from codecs marshalling their responses, to the problems that
+// are sent in response.  MapError may return nil to
+// leave err to DefaultErrorMapper.
+type ErrorMapper interface {
+	MapError(req *http.Request, err error) *Problem
+}
+
+// ErrorMapperFunc is a function that implements ErrorMapper.
+type ErrorMapperFunc func(req *http.Request, err error) *Problem
+
+// MapError calls f.
+func (f ErrorMapperFunc) MapError(req *http.Request, err error) *Problem {
+	return f(req, err)
+}
+
+// DefaultErrorMapper is the ErrorMapper that handles errors that a
+// Router's own ErrorMapper doesn't (see Router.SetErrorMapper).  It
+// maps errors as follows:
+//
+//   - A *Problem (or an error wrapping one) is used as is.
+//   - A *ValidationError results in a 422 Unprocessable Content
+//     problem listing its Params.
+//   - ErrNotFound results in a 404 Not Found problem.
+//   - ErrConflict results in a 409 Conflict problem.
+//   - Any other error results in a 500 Internal Server Error problem.
+//
+// Only *Problem and *ValidationError values are exposed to clients;
+// the messages of other errors (which may describe server internals)
+// are not.  Use an ErrorMapper to expose them where that is safe.
+var DefaultErrorMapper ErrorMapper = ErrorMapperFunc(defaultMapError)
+
+func defaultMapError(_ *http.Request, err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		problem = NewProblem(http.StatusUnprocessableEntity, validation.Error())
+		problem.Extensions = map[string]interface{}{"invalid-params": validation.Params}
+		return problem
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return NewProblem(http.StatusNotFound, "The requested resource was not found")
+	case errors.Is(err, ErrConflict):
+		return NewProblem(http.StatusConflict, "The request conflicts with the current state of the resource")
+	}
+	return NewProblem(http.StatusInternalServerError, "An unexpected error occurred")
+}
+
+// SetErrorMapper sets the ErrorMapper that r uses to convert errors
+// returned by handlers to problems.  Errors that mapper returns nil
+// for are mapped by DefaultErrorMapper.  Groups (see Group) use their
+// parent's ErrorMapper unless they set their own.
+func (r *Router) SetErrorMapper(mapper ErrorMapper) {
+	r.mapper = mapper
+}
+
+// errorMapper returns the ErrorMapper set on s, or on its parent if
+// none was.
+func (s *settings) errorMapper() ErrorMapper {
+	if s.mapper == nil && s.parent != nil {
+		return s.parent.errorMapper()
+	}
+	return s.mapper
+}
+
+// problemFor converts err, returned while handling req, to a *Problem
+// using the ErrorMapper of the Router that routed req.
+func problemFor(req *http.Request, err error) *Problem {
+	if resource := requestResource(req); resource != nil && resource.router != nil {
+		if mapper := resource.router.errorMapper(); mapper != nil {
+			if problem := mapper.MapError(req, err); problem != nil {
+				return problem
+			}
+		}
+	}
+	return DefaultErrorMapper.MapError(req, err)
+}
+
+// An ErrorGetter is a Getter whose Get method returns an error,
+// rather than building error responses itself.  A non-nil error is
+// converted to a problem by the Router's ErrorMapper, and the returned
+// *Response is ignored.  A nil *Response with a nil error results in a
+// 204 No Content response.  The other error-returning interfaces
+// (ErrorQuerier, ErrorPoster, ErrorPutter, ErrorPatcher, and
+// ErrorDeleter) behave in the same way, and are routed in place of
+// their counterparts.
+type ErrorGetter interface {
+	Handler
+	Get(identifier string) (*Response, error)
+}
+
+// An ErrorQuerier is a Querier whose Query method returns an error.
+// See ErrorGetter.
+type ErrorQuerier interface {
+	Handler
+	Query() (*Response, error)
+}
+
+// An ErrorPoster is a Poster whose Post method returns an error.  See
+// ErrorGetter.
+type ErrorPoster interface {
+	Handler
+	Post() (*Response, error)
+}
+
+// An ErrorPutter is a Putter whose Put method returns an error.  See
+// ErrorGetter.
+type ErrorPutter interface {
+	Handler
+	Put(identifier string) (*Response, error)
+}
+
+// An ErrorPatcher is a Patcher whose Patch method returns an error.
+// See ErrorGetter.
+type ErrorPatcher interface {
+	Handler
+	Patch(identifier string) (*Response, error)
+}
+
+// An ErrorDeleter is a Deleter whose Delete method returns an error.
+// See ErrorGetter.
+type ErrorDeleter interface {
+	Handler
+	Delete(identifier string) (*Response, error)
+}
+
+// getMethod returns h's Get method, if h is a Getter or an
+// ErrorGetter.  The other *Method functions do the same for their
+// handler methods.
+func getMethod(h Handler, req *http.Request) (func(string) *Response, bool) {
+	switch h := h.(type) {
+	case Getter:
+		return h.Get, true
+	case ErrorGetter:
+		return idErrorMethod(req, h.Get), true
+	}
+	return nil, false
+}
+
+func queryMethod(h Handler, req *http.Request) (func() *Response, bool) {
+	switch h := h.(type) {
+	case Querier:
+		return h.Query, true
+	case ErrorQuerier:
+		return errorMethod(req, h.Query), true
+	}
+	return nil, false
+}
+
+func postMethod(h Handler, req *http.Request) (func() *Response, bool) {
+	switch h := h.(type) {
+	case Poster:
+		return h.Post, true
+	case ErrorPoster:
+		return errorMethod(req, h.Post), true
+	}
+	return nil, false
+}
+
+func putMethod(h Handler, req *http.Request) (func(string) *Response, bool) {
+	switch h := h.(type) {
+	case Putter:
+		return h.Put, true
+	case ErrorPutter:
+		return idErrorMethod(req, h.Put), true
+	}
+	return nil, false
+}
+
+func patchMethod(h Handler, req *http.Request) (func(string) *Response, bool) {
+	switch h := h.(type) {
+	case Patcher:
+		return h.Patch, true
+	case ErrorPatcher:
+		return idErrorMethod(req, h.Patch), true
+	}
+	return nil, false
+}
+
+func deleteMethod(h Handler, req *http.Request) (func(string) *Response, bool) {
+	switch h := h.(type) {
+	case Deleter:
+		return h.Delete, true
+	case ErrorDeleter:
+		return idErrorMethod(req, h.Delete), true
+	}
+	return nil, false
+}
+
+// errorMethod adapts an error-returning handler method to one that
+// returns a problem response for its error.
+func errorMethod(req *http.Request, f func() (*Response, error)) func() *Response {
+	return func() *Response {
+		resp, err := f()
+		return errorResponse(req, resp, err)
+	}
+}
+
+// idErrorMethod is errorMethod for methods that take an identifier.
+func idErrorMethod(req *http.Request, f func(string) (*Response, error)) func(string) *Response {
+	return func(id string) *Response {
+		resp, err := f(id)
+		return errorResponse(req, resp, err)
+	}
+}
+
+// errorResponse returns the response to req for the results of an
+// error-returning handler method.
+func errorResponse(req *http.Request, resp *Response, err error) *Response {
+	if err != nil {
+		return problemResponse(req, err)
+	}
+	if resp == nil {
+		resp = NewResponse(req)
+		resp.Status = http.StatusNoContent
+	}
+	return resp
+}
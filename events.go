@@ -0,0 +1,175 @@
+package silverback
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultEventHeartbeat = 15 * time.Second
+
+var eventStreamType = MIMEType{
+	Type:    "text",
+	SubType: "event-stream",
+}
+
+// An Event is a single server-sent event, sent to a client that is
+// watching a resource.
+type Event struct {
+	// ID is the event's id, which the client will send back in the
+	// Last-Event-ID header if it needs to reconnect.
+	ID string
+
+	// Event is the event's type.  If empty, clients will treat it as
+	// a "message" event.
+	Event string
+
+	// Retry, if non-zero, tells the client how long to wait before
+	// reconnecting if the stream is interrupted.
+	Retry time.Duration
+
+	// Data is the event's payload.  It will be marshalled using the
+	// codec that best matches the request's Accept header, ignoring
+	// text/event-stream itself.
+	Data interface{}
+}
+
+// wantsEvents returns whether or not accept prefers an event stream
+// over any of the types supported by codecs.  Only explicit
+// text/event-stream entries count; wildcards are assumed to be asking
+// for a regular response.
+func wantsEvents(accept Accept, codecs []Codec) bool {
+	for _, entry := range accept {
+		if entry.Type == eventStreamType.Type && entry.SubType == eventStreamType.SubType {
+			return true
+		}
+		if codec, _ := entry.bestCodec(codecs); codec != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// eventsOr returns an http.Handler that passes requests preferring
+// an event stream to events, and all others to fallback.  If fallback
+// is nil, requests that don't prefer an event stream receive a 406
+// response.
+func (r *Router) eventsOr(events, fallback http.Handler) http.Handler {
+	h := http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if wantsEvents(ParseAcceptHeader(req.Header), r.codecs()) {
+			events.ServeHTTP(writer, req)
+			return
+		}
+		if fallback == nil {
+			problem := NewProblem(http.StatusNotAcceptable, "This resource is only available as text/event-stream")
+			WriteProblem(writer, req, problem, r.codecs())
+			return
+		}
+		fallback.ServeHTTP(writer, req)
+	})
+	names := append(append([]string(nil), handlerMethods(events)...), handlerMethods(fallback)...)
+	return namedHandler{Handler: h, names: names}
+}
+
+// eventCodec returns the codec that should be used to marshal event
+// data for req.
+func (r *Router) eventCodec(req *http.Request) Codec {
+	if codec := ParseAcceptHeader(req.Header).Codec(r.codecs()); codec != nil {
+		return codec
+	}
+	for _, codec := range r.codecs() {
+		if types := codec.Types(); len(types) > 0 {
+			return codec.New(types[0])
+		}
+	}
+	return nil
+}
+
+// writeEvents writes events to writer as a text/event-stream until
+// the events channel is closed or the client disconnects.  A comment
+// is sent as a heartbeat whenever there has been no event for the
+// router's heartbeat duration, to keep idle connections open.
+func (r *Router) writeEvents(writer http.ResponseWriter, req *http.Request, events <-chan Event) {
+	codec := r.eventCodec(req)
+	if codec == nil {
+		problem := NewProblem(http.StatusNotAcceptable, "No codec is available for event data")
+		WriteProblem(writer, req, problem, r.codecs())
+		return
+	}
+	writer.Header().Set("Content-Type", eventStreamType.String())
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	flusher, _ := writer.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	heartbeat := time.NewTicker(r.eventHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := writer.Write([]byte(":\n\n")); err != nil {
+				return
+			}
+			flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			body, err := formatEvent(event, codec)
+			if err != nil {
+				return
+			}
+			if _, err := writer.Write(body); err != nil {
+				return
+			}
+			flush()
+			heartbeat.Reset(r.eventHeartbeat())
+		}
+	}
+}
+
+// formatEvent formats event using the text/event-stream format,
+// marshalling its data with codec.
+func formatEvent(event Event, codec Codec) ([]byte, error) {
+	data, err := codec.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if event.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", eventField(event.ID))
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", eventField(event.Event))
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %s\n", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// eventField strips line breaks from an event field, since they would
+// end the field early.
+var eventField = strings.NewReplacer("\r", "", "\n", "").Replace
+
+func (r *Router) eventHeartbeat() time.Duration {
+	heartbeat := r.settings.eventHeartbeat()
+	if heartbeat <= 0 {
+		return defaultEventHeartbeat
+	}
+	return heartbeat
+}
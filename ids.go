@@ -0,0 +1,164 @@
+package silverback
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// idRoute returns the route template for the identifiers of handler,
+// using varName as the name of the route variable.  The variables of
+// an IDTemplater's template are prefixed with varName and a dot, so
+// that they can't collide with the variables of parent resources.
+func idRoute(handler Handler, varName string) string {
+	if templater, ok := handler.(IDTemplater); ok {
+		return mapTemplate(templater.IDTemplate(), func(name, pattern string) string {
+			if pattern == "" {
+				return "{" + varName + "." + name + "}"
+			}
+			return "{" + varName + "." + name + ":" + pattern + "}"
+		})
+	}
+	if patterner, ok := handler.(IDPatterner); ok {
+		return "{" + varName + ":" + patterner.IDPattern() + "}"
+	}
+	return "{" + varName + "}"
+}
+
+// requestKey returns the identifier and key of the instance of
+// handler that vars (the route variables of a request) refer to.  The
+// identifier of an IDTemplater is its template, with each variable
+// replaced by its value.  If vars doesn't include the identifier
+// (e.g. for requests to a collection), ok will be false.
+func requestKey(handler Handler, varName string, vars map[string]string) (id string, key Key, ok bool) {
+	templater, isTemplater := handler.(IDTemplater)
+	if !isTemplater {
+		id, ok = vars[varName]
+		if !ok {
+			return "", nil, false
+		}
+		return id, Key{"id": id}, true
+	}
+	key = make(Key)
+	ok = true
+	id = mapTemplate(templater.IDTemplate(), func(name, _ string) string {
+		value, found := vars[varName+"."+name]
+		ok = ok && found
+		key[name] = value
+		return value
+	})
+	if !ok {
+		return "", nil, false
+	}
+	return id, key, true
+}
+
+// mapTemplate calls f for each variable in a route template, returning
+// the template with each variable replaced by f's result.  Variables
+// are written as {name} or {name:pattern}; patterns may contain
+// braces, as long as they are balanced.
+func mapTemplate(tmpl string, f func(name, pattern string) string) string {
+	var mapped strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start == -1 {
+			break
+		}
+		end := closingBrace(tmpl, start)
+		if end == -1 {
+			break
+		}
+		mapped.WriteString(tmpl[:start])
+		name, pattern := tmpl[start+1:end], ""
+		if colon := strings.IndexByte(name, ':'); colon != -1 {
+			name, pattern = name[:colon], name[colon+1:]
+		}
+		mapped.WriteString(f(strings.TrimSpace(name), pattern))
+		tmpl = tmpl[end+1:]
+	}
+	mapped.WriteString(tmpl)
+	return mapped.String()
+}
+
+// templateRegexp returns a regular expression matching identifiers
+// built from tmpl, along with the names of tmpl's variables in order.
+// The value of the variable at index i is captured by the group named
+// templateGroup(i).
+func templateRegexp(tmpl string) (*regexp.Regexp, []string, error) {
+	var names, patterns []string
+	// Variables are replaced by a marker, so that the rest of the
+	// template can be quoted before the variables' patterns are
+	// added.
+	const marker = "\x00"
+	marked := mapTemplate(tmpl, func(name, pattern string) string {
+		if pattern == "" {
+			pattern = "[^/]+"
+		}
+		names = append(names, name)
+		patterns = append(patterns, pattern)
+		return marker
+	})
+	literals := strings.Split(regexp.QuoteMeta(marked), marker)
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, literal := range literals {
+		expr.WriteString(literal)
+		if i < len(patterns) {
+			expr.WriteString("(?P<" + templateGroup(i) + ">" + patterns[i] + ")")
+		}
+	}
+	expr.WriteString("$")
+	match, err := regexp.Compile(expr.String())
+	return match, names, err
+}
+
+// templateGroup returns the name of the regexp group capturing the
+// variable at index i of a template.
+func templateGroup(i int) string {
+	return "v" + strconv.Itoa(i)
+}
+
+// closingBrace returns the index of the brace closing the one at
+// start, or -1 if it is never closed.
+func closingBrace(tmpl string, start int) int {
+	depth := 0
+	for i := start; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseID calls h.ParseKey and h.ParseID, if h is a KeyParser or an
+// IDParser, with the key and identifier of resource.  If they can't
+// be parsed, it returns the *Problem that was returned, or a 400 Bad
+// Request problem for any other error.
+func parseID(h Handler, resource *Resource) *Problem {
+	if resource == nil {
+		return nil
+	}
+	var err error
+	if parser, ok := h.(KeyParser); ok {
+		err = parser.ParseKey(resource.Key)
+	}
+	if parser, ok := h.(IDParser); ok && err == nil {
+		err = parser.ParseID(resource.ID)
+	}
+	if err == nil {
+		return nil
+	}
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+	return problemf(http.StatusBadRequest, "Invalid identifier %q: %v", resource.ID, err)
+}
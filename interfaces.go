@@ -47,6 +47,23 @@ type Poster interface {
 	Post() *Response
 }
 
+// A Creator is a Poster that reports the identifier of the resource
+// instance that its Post method created.  CreatedID is called after
+// Post returns; an empty identifier means that nothing was created.
+// Posters whose response bodies are Identified don't need to be
+// Creators, since the body's ID is used instead.
+//
+// When an instance was created, Post didn't set the response's
+// Status, and the handler's instances have a route (e.g. it is a
+// Getter or a Putter), the Router responds with 201 Created and a
+// Location header for the new instance.  If the response body is the
+// created instance (i.e. it is Identified with the same identifier), a
+// matching Content-Location header is added too.
+type Creator interface {
+	Poster
+	CreatedID() string
+}
+
 // A Putter is a controller type that can handle PUT requests.
 type Putter interface {
 	Handler
@@ -64,3 +81,176 @@ type Deleter interface {
 	Handler
 	Delete(identifier string) *Response
 }
+
+// Common patterns for IDPatterner.IDPattern.
+const (
+	// NumericID matches identifiers made up of decimal digits.
+	NumericID = "[0-9]+"
+
+	// UUID matches identifiers in the canonical (hyphenated) UUID
+	// format.
+	UUID = "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}"
+)
+
+// An IDPatterner is a controller type whose identifiers must match a
+// regular expression, such as NumericID or UUID.  The pattern becomes
+// part of the route for the resource's instances, so requests with
+// identifiers that don't match it receive a 404 Not Found response.
+// The pattern must not contain capturing groups.
+type IDPatterner interface {
+	Handler
+	IDPattern() string
+}
+
+// An IDParser is a controller type that parses identifiers before
+// they are passed to its methods.  ParseID is called on the handler
+// returned by New, before BeforeHandle and the handler method, so it
+// may store the parsed (typed) identifier on the handler for the
+// method to use.
+//
+// If ParseID returns an error, the handler method is not called.  A
+// *Problem returned from ParseID (such as a 404 Not Found problem) is
+// used as the response; any other error results in a 400 Bad Request
+// problem.
+type IDParser interface {
+	Handler
+	ParseID(identifier string) error
+}
+
+// A Singleton is a controller type for a resource that has exactly
+// one instance, and so no identifier, such as /me or /settings.  When
+// Singleton returns true, the Get, Put, Patch, Delete, and Watch
+// methods of the handler are routed to its Path() itself (rather than
+// Path()/{id}) and receive an empty identifier, while Query and
+// WatchCollection are not routed at all.  Post, if the handler is a
+// Poster, is routed to Path() as usual.
+type Singleton interface {
+	Handler
+	Singleton() bool
+}
+
+// A Key is a structured identifier, mapping the names of the
+// variables in an IDTemplater's template to their values.  Handlers
+// that aren't IDTemplaters have a Key with a single "id" value.
+type Key map[string]string
+
+// An IDTemplater is a controller type whose instances are identified
+// by a template of gorilla/mux route variables, rather than a single
+// path segment.  For example, a template of "{currency}/{date}"
+// results in routes like /rates/{currency}/{date}, and a template of
+// "{path:.*}" allows identifiers that contain slashes.  IDTemplater
+// takes precedence over IDPatterner.
+//
+// Methods that take an identifier string (such as Get) receive the
+// template with each variable replaced by its value (e.g.
+// "USD/2024-01-01"); the structured Key is passed to ParseKey, if the
+// handler is a KeyParser, and is available using RequestResource.
+// Variable names must be unique within the template.
+type IDTemplater interface {
+	Handler
+	IDTemplate() string
+}
+
+// A KeyParser is a controller type that parses the Key identifying an
+// instance of a resource before it is passed to its methods.  It
+// behaves like an IDParser (and is called before ParseID, when a
+// handler implements both), but receives each of the values in an
+// IDTemplater's template separately.
+type KeyParser interface {
+	Handler
+	ParseKey(key Key) error
+}
+
+// An Exister is a controller type that can report whether or not an
+// instance of a resource exists.  When an Exister is the parent of a
+// child router (see Router.Sub), requests for nested resources under
+// an instance that doesn't exist receive a 404 Not Found response.
+type Exister interface {
+	Handler
+	Exists(identifier string) bool
+}
+
+// A CollectionPutter is a controller type that can handle PUT
+// requests that replace a whole collection of resources.  It will
+// usually be a BodyReceiver whose RequestBody is a pointer to a
+// slice.
+type CollectionPutter interface {
+	Handler
+	PutCollection() *Response
+}
+
+// A CollectionPatcher is a controller type that can handle PATCH
+// requests that modify many instances of a resource at once.  It will
+// usually be a BodyReceiver whose RequestBody is a pointer to a
+// slice, and respond with a MultiStatus body reporting the result for
+// each instance.
+type CollectionPatcher interface {
+	Handler
+	PatchCollection() *Response
+}
+
+// A CollectionDeleter is a controller type that can handle DELETE
+// requests for the instances of a resource matching a filter, which
+// it should read from the request's query parameters.  It will
+// usually respond with a MultiStatus body reporting the result for
+// each instance.
+type CollectionDeleter interface {
+	Handler
+	DeleteCollection() *Response
+}
+
+// An Action handles a custom operation on an instance of a resource,
+// such as cancelling an order.  It is called in the same way as a
+// Putter's Put method.
+type Action func(identifier string) *Response
+
+// An Actioner is a controller type with operations that don't fit
+// the standard methods.  Each action is routed to POST requests for
+// the action's name under an instance's path; for example, a "cancel"
+// action on a handler with a Path() of "/orders" is routed to
+// /orders/{id}/cancel.  Actions on a Singleton are routed directly
+// under its Path().
+//
+// Actions is called once when the handler is routed, to find the
+// action names, and again on the handler returned by New for each
+// request, so the returned actions may use the request's handler
+// (e.g. as method values).  Requests for actions decode request bodies
+// (see BodyReceiver) and call BeforeHandle and AfterHandle, like any
+// other method.
+type Actioner interface {
+	Handler
+	Actions() map[string]Action
+}
+
+// A BodyReceiver is a controller type that expects a request body.
+// RequestBody should return a pointer to the value that the request
+// body should be unmarshalled to.  Before calling Post, Put, or
+// Patch, the Router will unmarshal the request body to that value
+// using the codec matching the request's Content-Type header.
+type BodyReceiver interface {
+	Handler
+	RequestBody() interface{}
+}
+
+// A Watcher is a controller type that can stream changes to a single
+// instance of a resource as server-sent events.  GET requests for the
+// resource will be routed to Watch when the client prefers the
+// text/event-stream MIME type over any registered codecs.
+//
+// The lastEventID will be the value of the Last-Event-ID header, if
+// the client is resuming a previous stream, so that the watcher can
+// send any events the client missed.  Watch should close the returned
+// channel when there are no more events; it should also stop sending
+// and close the channel when the request's context is done.
+type Watcher interface {
+	Handler
+	Watch(identifier, lastEventID string) <-chan Event
+}
+
+// A CollectionWatcher is a controller type that can stream changes to
+// a collection of resources as server-sent events.  It is the
+// collection equivalent of a Watcher.
+type CollectionWatcher interface {
+	Handler
+	WatchCollection(lastEventID string) <-chan Event
+}
@@ -0,0 +1,89 @@
+package silverback
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetLanguages sets the languages that r's handlers can respond in,
+// most preferred first, as language tags (e.g. "en-US" or "fr").  The
+// language for each request is chosen from them using its
+// Accept-Language header and passed to ContextHandlers (see Language).
+// Groups (see Group) use their parent's languages unless they set
+// their own.
+func (r *Router) SetLanguages(languages ...string) {
+	r.languageList = languages
+}
+
+// languages returns the languages set on s, or on its parent if none
+// were.
+func (s *settings) languages() []string {
+	if len(s.languageList) == 0 && s.parent != nil {
+		return s.parent.languages()
+	}
+	return s.languageList
+}
+
+// languageRange is an entry in an Accept-Language header.
+type languageRange struct {
+	tag     string
+	quality float64
+}
+
+// negotiateLanguage returns the language in supported that best
+// matches an Accept-Language header.  Ranges are tried in order of
+// quality.  Each range matches the supported languages that equal it
+// or start with it and a hyphen, ignoring case, so "en" matches
+// "en-US"; when none do, its trailing subtags are removed one at a
+// time and the shorter range is tried, so "en-GB" also matches
+// "en-US".  A range of "*", or no match at all, results in the first
+// supported language.
+func negotiateLanguage(header string, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	var ranges []languageRange
+	for _, value := range strings.Split(header, ",") {
+		parts := strings.Split(value, ";")
+		lr := languageRange{tag: strings.TrimSpace(parts[0]), quality: 1}
+		for _, param := range parts[1:] {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				lr.quality = q
+			}
+		}
+		if lr.tag != "" && lr.quality > 0 {
+			ranges = append(ranges, lr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	for _, lr := range ranges {
+		if lr.tag == "*" {
+			return supported[0]
+		}
+		for tag := lr.tag; tag != ""; tag = parentLanguage(tag) {
+			for _, language := range supported {
+				if strings.EqualFold(language, tag) || strings.HasPrefix(strings.ToLower(language), strings.ToLower(tag)+"-") {
+					return language
+				}
+			}
+		}
+	}
+	return supported[0]
+}
+
+// parentLanguage returns tag without its last subtag, or an empty
+// string if tag has only one subtag.
+func parentLanguage(tag string) string {
+	i := strings.LastIndexByte(tag, '-')
+	if i == -1 {
+		return ""
+	}
+	return tag[:i]
+}
@@ -0,0 +1,43 @@
+package silverback
+
+import "net/http"
+
+// A Link is a link from a resource to a related resource.
+type Link struct {
+	// Rel is the link's relation type, e.g. "next" or "author".
+	Rel string
+
+	// Href is the link's target.
+	Href string
+
+	// Title is an optional human-readable label for the link.
+	Title string
+}
+
+// A Linker is a controller type that adds links to its responses, for
+// codecs that support hypermedia (such as HAL).  Links is called
+// after the handler method has returned, so it may use any state that
+// the method set.
+type Linker interface {
+	Handler
+	Links() []Link
+}
+
+// An Identified value knows its own identifier.  Codecs and the
+// Router use it to build links to response bodies, and to the items
+// in collection responses.
+type Identified interface {
+	ID() string
+}
+
+// addLinks stores h's links on the resource that req was routed to,
+// if h is a Linker.
+func addLinks(h Handler, req *http.Request) {
+	linker, ok := h.(Linker)
+	if !ok {
+		return
+	}
+	if resource := requestResource(req); resource != nil {
+		resource.Links = append(resource.Links, linker.Links()...)
+	}
+}
@@ -0,0 +1,84 @@
+package silverback
+
+import "net/http"
+
+// Middleware wraps the http.Handler for a routed handler method.
+// Middleware may call RequestResource to find out which resource,
+// Handler, and handler method the request was routed to.
+type Middleware func(http.Handler) http.Handler
+
+// A RouteOption configures the routes for a single call to
+// Router.Route.
+type RouteOption func(*routeOptions)
+
+type routeOptions struct {
+	middleware []Middleware
+	methods    map[string][]Middleware
+}
+
+func newRouteOptions(options []RouteOption) *routeOptions {
+	o := &routeOptions{methods: make(map[string][]Middleware)}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// chain returns the middleware for the handler method called method,
+// outermost first.
+func (o *routeOptions) chain(method string) []Middleware {
+	if o == nil {
+		return nil
+	}
+	chain := make([]Middleware, 0, len(o.middleware)+len(o.methods[method]))
+	chain = append(chain, o.middleware...)
+	return append(chain, o.methods[method]...)
+}
+
+// WithMiddleware adds middleware to every route for a handler.
+func WithMiddleware(middleware ...Middleware) RouteOption {
+	return func(o *routeOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithMethodMiddleware adds middleware to the routes for a single
+// handler method, named as in Resource.Method (e.g. "Get", "Query",
+// or "Post"; "Action" for all of an Actioner's actions).
+func WithMethodMiddleware(method string, middleware ...Middleware) RouteOption {
+	return func(o *routeOptions) {
+		o.methods[method] = append(o.methods[method], middleware...)
+	}
+}
+
+// AddMiddleware adds middleware to every handler method routed by r,
+// including those routed before AddMiddleware is called and those
+// routed by child routers (see Sub).
+//
+// Middleware runs in a deterministic order, from outermost to
+// innermost:
+//
+//  1. Middleware added with AddMiddleware, in the order it was added.
+//  2. Middleware added to the handler using WithMiddleware, in order.
+//  3. Middleware added to the handler method using
+//     WithMethodMiddleware, in order.
+//
+// Middleware runs after the request has been matched to a route and
+// tagged with its Resource, but before anything else: parent
+// existence checks (see Exister), request body decoding, identifier
+// parsing, BeforeHandle, the handler method, and AfterHandle all run
+// inside it.  Responses that the Router generates without routing to
+// a handler method (404 Not Found for unknown paths, 405 Method Not
+// Allowed, and OPTIONS) don't pass through it; use gorilla/mux's Use
+// for middleware that should see those too.
+func (r *Router) AddMiddleware(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// wrap wraps h in middleware, so that middleware[0] is outermost.
+func wrap(middleware []Middleware, h http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
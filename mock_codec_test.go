@@ -18,6 +18,10 @@ func makeCodec(mimeType, mimeSubType string) *mockCodec {
 	}
 }
 
+func (m *mockCodec) New(silverback.MIMEType) silverback.Codec {
+	return m
+}
+
 func (m *mockCodec) Types() []silverback.MIMEType {
 	return m.types
 }
@@ -0,0 +1,49 @@
+package silverback
+
+import "net/http"
+
+// A MultiStatus is a Response Body reporting the result of a bulk
+// operation (see CollectionPutter, CollectionPatcher, and
+// CollectionDeleter) for each item that it affected.  Responses with
+// a MultiStatus body default to a 207 Multi-Status status code.
+type MultiStatus []ItemStatus
+
+// An ItemStatus is the result of a bulk operation for a single item.
+type ItemStatus struct {
+	// ID identifies the item.
+	ID string `json:"id,omitempty" xml:"id,omitempty"`
+
+	// Status is the HTTP status code that a request for the item
+	// alone would have received.
+	Status int `json:"status" xml:"status"`
+
+	// Body is the item's representation, if any.
+	Body interface{} `json:"body,omitempty" xml:"body,omitempty"`
+
+	// Problem describes why the operation failed for the item, if it
+	// did.
+	Problem *Problem `json:"problem,omitempty" xml:"problem,omitempty"`
+}
+
+// ItemOK returns an ItemStatus for an item that was processed
+// successfully, with status defaulting to 200 OK if it is 0.
+func ItemOK(id string, status int, body interface{}) ItemStatus {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return ItemStatus{
+		ID:     id,
+		Status: status,
+		Body:   body,
+	}
+}
+
+// ItemProblem returns an ItemStatus for an item that couldn't be
+// processed, using p's status.
+func ItemProblem(id string, p *Problem) ItemStatus {
+	return ItemStatus{
+		ID:      id,
+		Status:  p.Status,
+		Problem: p,
+	}
+}
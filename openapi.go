@@ -0,0 +1,405 @@
+package silverback
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.1.0"
+
+// A Modeler is a controller type that declares the Go type of the
+// values representing instances of its resource, so that generated
+// documentation (see Router.OpenAPI) can describe its bodies.  Model
+// should return a value of that type, or a pointer to one; its
+// contents are ignored.
+//
+// Request body schemas are derived from RequestBody, for handlers that
+// are BodyReceivers, and from Model otherwise.
+type Modeler interface {
+	Handler
+	Model() interface{}
+}
+
+// OpenAPIInfo is the metadata for a generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// An OpenAPIDocument is a generated OpenAPI document.  It is a plain
+// map, so that any codec capable of marshalling maps (such as JSON or
+// YAML codecs) can render it.
+type OpenAPIDocument map[string]interface{}
+
+// OpenAPI generates an OpenAPI 3.1 document describing every route in
+// r.Routes().  Operations are derived from the methods that each
+// handler implements, media types from the codecs of the router or
+// group that routed each path (see RouteInfo.ContentTypes), and
+// schemas from the types declared by Modeler and BodyReceiver handlers
+// and by the methods of typed handlers (such as TypedGetter).  Errors
+// are documented as RFC 9457 problem details.
+func (r *Router) OpenAPI(info OpenAPIInfo) OpenAPIDocument {
+	gen := &schemaGenerator{
+		components: map[string]interface{}{},
+		names:      map[reflect.Type]string{},
+	}
+	paths := map[string]interface{}{}
+	for _, route := range r.Routes() {
+		item, _ := paths[openAPIPath(route.Path)].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[openAPIPath(route.Path)] = item
+		}
+		for method, names := range route.Methods {
+			if method == "HEAD" {
+				continue
+			}
+			item[strings.ToLower(method)] = gen.operation(route, names, route.ContentTypes)
+		}
+	}
+	apiInfo := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		apiInfo["description"] = info.Description
+	}
+	gen.schema(reflect.TypeOf(Problem{}))
+	return OpenAPIDocument{
+		"openapi": openAPIVersion,
+		"info":    apiInfo,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": gen.components,
+		},
+	}
+}
+
+// OpenAPIHandler returns an http.Handler that responds to GET requests
+// with r.OpenAPI(info), rendered using the codec that best matches
+// the request's Accept header.  The document is generated on each
+// request, so it includes routes added after OpenAPIHandler is
+// called.
+func (r *Router) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	get := http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		resp := NewResponse(req)
+		resp.Body = r.OpenAPI(info)
+		WriteResponse(writer, resp, r.codecs())
+	})
+	return r.allowMethods(methods{"GET": get})
+}
+
+// ServeOpenAPI routes GET requests for docPath to r.OpenAPIHandler.
+func (r *Router) ServeOpenAPI(docPath string, info OpenAPIInfo) {
+	r.Handle(docPath, r.OpenAPIHandler(info))
+}
+
+// openAPIPath converts a gorilla/mux path template to an OpenAPI path
+// template, removing variable patterns.
+func openAPIPath(pattern string) string {
+	return mapTemplate(pattern, func(name, _ string) string {
+		return "{" + name + "}"
+	})
+}
+
+// operation returns the OpenAPI operation object for the handler
+// methods names, which handle one HTTP method on route.
+func (gen *schemaGenerator) operation(route RouteInfo, names []string, mediaTypes []string) map[string]interface{} {
+	summary := strings.Join(names, ", ")
+	if route.Action != "" {
+		summary = route.Action
+	}
+	op := map[string]interface{}{
+		"summary": summary,
+		"tags":    []string{path.Base(route.Handler.Path())},
+	}
+	if params := pathParameters(route); len(params) > 0 {
+		op["parameters"] = params
+	}
+	model := handlerModel(route.Handler)
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "Problem details",
+			"content": map[string]interface{}{
+				"application/problem+json": map[string]interface{}{
+					"schema": gen.schema(reflect.TypeOf(Problem{})),
+				},
+			},
+		},
+	}
+	for _, name := range names {
+		switch name {
+		case "Get", "Put", "Patch", "Post", "Action":
+			responses["200"] = gen.response("OK", model, mediaTypes)
+		case "Query", "PutCollection":
+			var items reflect.Type
+			if model != nil {
+				items = reflect.SliceOf(model)
+			}
+			responses["200"] = gen.response("OK", items, mediaTypes)
+		case "PatchCollection", "DeleteCollection":
+			responses["207"] = gen.response("Multi-Status", reflect.TypeOf(MultiStatus{}), mediaTypes)
+		case "Delete":
+			responses["200"] = map[string]interface{}{"description": "OK"}
+		case "Watch", "WatchCollection":
+			if _, ok := responses["200"]; !ok {
+				responses["200"] = map[string]interface{}{"description": "OK"}
+			}
+			resp := responses["200"].(map[string]interface{})
+			content, _ := resp["content"].(map[string]interface{})
+			if content == nil {
+				content = map[string]interface{}{}
+				resp["content"] = content
+			}
+			content[eventStreamType.String()] = map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			}
+		}
+		switch name {
+		case "Put", "Patch", "Post", "Action", "PutCollection", "PatchCollection":
+			if body := requestModel(route.Handler, name); body != nil {
+				op["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content":  gen.content(body, mediaTypes),
+				}
+			}
+		}
+		if typed, ok := findTypedMethod(route.Handler, name); ok {
+			gen.typedOperation(op, responses, typed, mediaTypes)
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// typedOperation documents the bodies of a typed handler method (see
+// TypedGetter) using its parameter and result types.
+func (gen *schemaGenerator) typedOperation(op, responses map[string]interface{}, typed typedMethod, mediaTypes []string) {
+	if typed.bodyType != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  gen.content(derefType(typed.bodyType), mediaTypes),
+		}
+	}
+	if typed.resultType == nil {
+		delete(responses, "200")
+		responses["204"] = map[string]interface{}{"description": "No Content"}
+		return
+	}
+	responses["200"] = gen.response("OK", derefType(typed.resultType), mediaTypes)
+}
+
+// response returns an OpenAPI response object with a body of type t.
+// If t is nil, the response has no documented body.
+func (gen *schemaGenerator) response(description string, t reflect.Type, mediaTypes []string) map[string]interface{} {
+	resp := map[string]interface{}{"description": description}
+	if t != nil {
+		resp["content"] = gen.content(t, mediaTypes)
+	}
+	return resp
+}
+
+// content returns an OpenAPI content map, using the schema for t for
+// each media type.
+func (gen *schemaGenerator) content(t reflect.Type, mediaTypes []string) map[string]interface{} {
+	schema := gen.schema(t)
+	content := make(map[string]interface{}, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = map[string]interface{}{"schema": schema}
+	}
+	return content
+}
+
+// pathParameters returns the OpenAPI parameter objects for the
+// variables in route's path.
+func pathParameters(route RouteInfo) []interface{} {
+	names := make([]string, 0, len(route.Vars))
+	for name := range route.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		schema := map[string]interface{}{"type": "string"}
+		if pattern := route.Vars[name]; pattern != "" {
+			schema["pattern"] = "^" + pattern + "$"
+		}
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+	return params
+}
+
+// handlerModel returns the type declared by handler's Model method,
+// if it is a Modeler.
+func handlerModel(handler Handler) reflect.Type {
+	modeler, ok := handler.(Modeler)
+	if !ok {
+		return nil
+	}
+	return derefType(reflect.TypeOf(modeler.Model()))
+}
+
+// requestModel returns the type of the request bodies that handler
+// expects for the handler method called name.
+func requestModel(handler Handler, name string) reflect.Type {
+	if receiver, ok := handler.(BodyReceiver); ok {
+		if t := derefType(reflect.TypeOf(receiver.RequestBody())); t != nil {
+			return t
+		}
+	}
+	model := handlerModel(handler)
+	if model != nil && (name == "PutCollection" || name == "PatchCollection") {
+		return reflect.SliceOf(model)
+	}
+	return model
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator builds JSON Schemas (as used by OpenAPI 3.1) for Go
+// types, using their JSON representation.  Named struct types are
+// added to components and referenced by name.
+type schemaGenerator struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+// schema returns the schema for t.
+func (gen *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	t = derefType(t)
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": gen.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": gen.schema(t.Elem())}
+	case reflect.Struct:
+		return gen.structRef(t)
+	default:
+		// Interfaces (and anything else) may hold any value.
+		return map[string]interface{}{}
+	}
+}
+
+// structRef returns the schema for struct type t.  Named types are
+// added to components (once) and referenced; anonymous types are
+// inlined.
+func (gen *schemaGenerator) structRef(t reflect.Type) map[string]interface{} {
+	if t.Name() == "" {
+		return gen.structSchema(t)
+	}
+	name, ok := gen.names[t]
+	if !ok {
+		name = gen.componentName(t)
+		gen.names[t] = name
+		// Register the name before generating the schema, so that
+		// recursive types reference themselves.
+		gen.components[name] = nil
+		gen.components[name] = gen.structSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName returns a unique component name for t.
+func (gen *schemaGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := gen.components[name]; !taken {
+		return name
+	}
+	qualified := strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+	candidate := qualified
+	for i := 2; ; i++ {
+		if _, taken := gen.components[candidate]; !taken {
+			return candidate
+		}
+		candidate = qualified + strconv.Itoa(i)
+	}
+}
+
+// structSchema returns an object schema for the JSON representation
+// of struct type t.
+func (gen *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	gen.addFields(t, properties)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// addFields adds the JSON properties of struct type t to properties,
+// following encoding/json's rules for field names and embedded
+// structs.
+func (gen *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		fieldType := derefType(field.Type)
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			gen.addFields(fieldType, properties)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		switch fieldType.Kind() {
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = gen.schema(field.Type)
+	}
+}
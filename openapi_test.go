@@ -0,0 +1,184 @@
+package silverback_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type note struct {
+	ID      int       `json:"id"`
+	Text    string    `json:"text,omitempty"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Author  *author   `json:"author"`
+	secret  string
+}
+
+type author struct {
+	Name  string
+	Notes []note `json:"-"`
+}
+
+type noteHandler struct {
+	req  *http.Request
+	body note
+}
+
+func (h *noteHandler) New(r *http.Request) silverback.Handler {
+	return &noteHandler{req: r}
+}
+
+func (h *noteHandler) Path() string {
+	return "/notes"
+}
+
+func (h *noteHandler) IDPattern() string {
+	return silverback.NumericID
+}
+
+func (h *noteHandler) Model() interface{} {
+	return note{}
+}
+
+func (h *noteHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *noteHandler) Get(id string) *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+func (h *noteHandler) Delete(id string) *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+func (h *noteHandler) Query() *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+func (h *noteHandler) Post() *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		router *silverback.Router
+		doc    map[string]interface{}
+	)
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/openapi", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	lookup := func(keys ...string) interface{} {
+		var value interface{} = doc
+		for _, key := range keys {
+			Expect(value).To(HaveKey(key))
+			value = value.(map[string]interface{})[key]
+		}
+		return value
+	}
+
+	BeforeEach(func() {
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSON{})
+		router.Route(&noteHandler{})
+		router.ServeOpenAPI("/openapi", silverback.OpenAPIInfo{Title: "Notes", Version: "1.0"})
+
+		recorder := get("application/json")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &doc)).To(Succeed())
+	})
+
+	It("describes the document", func() {
+		Expect(doc).To(HaveKeyWithValue("openapi", "3.1.0"))
+		Expect(lookup("info")).To(Equal(map[string]interface{}{"title": "Notes", "version": "1.0"}))
+	})
+
+	It("documents operations for each implemented method", func() {
+		Expect(lookup("paths", "/notes")).To(HaveKey("get"))
+		Expect(lookup("paths", "/notes")).To(HaveKey("post"))
+		Expect(lookup("paths", "/notes")).ToNot(HaveKey("put"))
+		Expect(lookup("paths", "/notes/{id}")).To(HaveKey("get"))
+		Expect(lookup("paths", "/notes/{id}")).To(HaveKey("delete"))
+		Expect(lookup("paths", "/notes/{id}")).ToNot(HaveKey("head"))
+	})
+
+	It("documents path parameters and their patterns", func() {
+		params := lookup("paths", "/notes/{id}", "get", "parameters")
+		Expect(params).To(ConsistOf(map[string]interface{}{
+			"name":     "id",
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string", "pattern": "^[0-9]+$"},
+		}))
+	})
+
+	It("documents bodies using the registered codecs and the handler's model", func() {
+		item := lookup("paths", "/notes/{id}", "get", "responses", "200", "content")
+		Expect(item).To(Equal(map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/note"}},
+			"text/json":        map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/note"}},
+		}))
+		query := lookup("paths", "/notes", "get", "responses", "200", "content", "application/json", "schema")
+		Expect(query).To(Equal(map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"$ref": "#/components/schemas/note"},
+		}))
+		Expect(lookup("paths", "/notes", "post", "requestBody", "content")).To(HaveKey("application/json"))
+		Expect(lookup("paths", "/notes", "post", "responses", "default", "content")).To(HaveKey("application/problem+json"))
+	})
+
+	It("derives schemas from the JSON representation of types", func() {
+		Expect(lookup("components", "schemas", "note", "properties")).To(Equal(map[string]interface{}{
+			"id":      map[string]interface{}{"type": "integer", "format": "int64"},
+			"text":    map[string]interface{}{"type": "string"},
+			"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"created": map[string]interface{}{"type": "string", "format": "date-time"},
+			"author":  map[string]interface{}{"$ref": "#/components/schemas/author"},
+		}))
+		Expect(lookup("components", "schemas", "author", "properties")).To(HaveKey("Name"))
+		Expect(lookup("components", "schemas")).To(HaveKey("Problem"))
+	})
+
+	It("documents typed handlers using their method signatures", func() {
+		router.Route(&contactHandler{})
+		recorder := get("application/json")
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &doc)).To(Succeed())
+		Expect(lookup("paths", "/contacts", "post", "requestBody", "content", "application/json", "schema")).
+			To(Equal(map[string]interface{}{"$ref": "#/components/schemas/contactInput"}))
+		Expect(lookup("paths", "/contacts/{id}", "patch", "responses", "200", "content", "application/json", "schema")).
+			To(Equal(map[string]interface{}{"$ref": "#/components/schemas/contact"}))
+		Expect(lookup("paths", "/contacts/{id}", "delete", "responses")).To(HaveKey("204"))
+	})
+
+	It("documents groups using their own codecs", func() {
+		v2 := router.Group("/v2")
+		v2.AddCodec(&codecs.CBOR{})
+		v2.Route(&noteHandler{})
+		recorder := get("application/json")
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &doc)).To(Succeed())
+		content := lookup("paths", "/v2/notes/{id}", "get", "responses", "200", "content")
+		Expect(content).To(HaveKey("application/cbor"))
+		Expect(content).ToNot(HaveKey("application/json"))
+		Expect(lookup("paths", "/notes/{id}", "get", "responses", "200", "content")).ToNot(HaveKey("application/cbor"))
+	})
+
+	It("responds with 406 when no codec can render the document", func() {
+		Expect(get("application/yaml").Code).To(Equal(http.StatusNotAcceptable))
+	})
+})
@@ -0,0 +1,222 @@
+package silverback
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const problemNamespace = "urn:ietf:rfc:7807"
+
+// A Problem is a problem details object, as described in RFC 9457
+// (which obsoletes RFC 7807).  Every error response generated by the
+// Router uses a Problem as its body, and handlers may use one as a
+// Response's Body, or return one as an error from BeforeHandle or
+// AfterHandle, to do the same.
+//
+// Problems are rendered as application/problem+json when the
+// negotiated codec handles JSON, and application/problem+xml when it
+// handles XML.
+type Problem struct {
+	// Type is a URI reference identifying the problem type.  When
+	// empty, it is treated as "about:blank", meaning the problem has
+	// no semantics beyond its status code.
+	Type string `json:"type,omitempty"`
+
+	// Title is a short summary of the problem type.  If Type is
+	// empty, it should be the status code's standard text.
+	Title string `json:"title,omitempty"`
+
+	// Status is the HTTP status code for the problem.
+	Status int `json:"status,omitempty"`
+
+	// Detail is an explanation specific to this occurrence of the
+	// problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is a URI reference identifying this occurrence of the
+	// problem.
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions holds any extension members, which are rendered
+	// alongside the standard members.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a *Problem for status, using the status code's
+// standard text as its title.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error implements error, so that a *Problem can be returned from
+// methods like BeforeHandle.
+func (p *Problem) Error() string {
+	msg := p.Title
+	if msg == "" {
+		msg = http.StatusText(p.Status)
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return msg
+}
+
+// MarshalJSON marshals p to JSON, including any extension members
+// alongside the standard members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	type problem Problem
+	raw, err := json.Marshal((*problem)(p))
+	if err != nil {
+		return nil, err
+	}
+	var standard map[string]interface{}
+	if err := json.Unmarshal(raw, &standard); err != nil {
+		return nil, err
+	}
+	for name, value := range standard {
+		members[name] = value
+	}
+	return json.Marshal(members)
+}
+
+// MarshalXML marshals p to XML, using the element names and namespace
+// from RFC 9457 appendix B.
+func (p *Problem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: problemNamespace, Local: "problem"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	status := ""
+	if p.Status != 0 {
+		status = strconv.Itoa(p.Status)
+	}
+	members := []struct {
+		name  string
+		value string
+	}{
+		{"type", p.Type},
+		{"title", p.Title},
+		{"status", status},
+		{"detail", p.Detail},
+		{"instance", p.Instance},
+	}
+	for _, member := range members {
+		if member.value == "" {
+			continue
+		}
+		if err := e.EncodeElement(member.value, xml.StartElement{Name: xml.Name{Local: member.name}}); err != nil {
+			return err
+		}
+	}
+	for name, value := range p.Extensions {
+		if err := e.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// A ProblemMarshaler is a Codec with its own representation for
+// problem details, such as JSON:API error objects.  Problems that it
+// marshals keep the codec's MIME type, rather than being rendered as
+// application/problem+json or application/problem+xml.
+type ProblemMarshaler interface {
+	Codec
+	MarshalProblem(*Problem) ([]byte, error)
+}
+
+// problemCodec returns the codec that should be used to render a
+// problem for req, along with the MIME type it was matched with.  If
+// no codec matches req's Accept header, a codec that handles JSON is
+// preferred, since problems need to be rendered even when content
+// negotiation has failed.
+func problemCodec(req *http.Request, codecs []Codec) (Codec, MIMEType) {
+	if req != nil {
+		accept := ParseAcceptHeader(req.Header)
+		if codec, matched := accept.bestCodec(codecs); codec != nil {
+			return codec, matched
+		}
+	}
+	for _, codec := range codecs {
+		for _, supported := range codec.Types() {
+			if supported.SubType == "json" {
+				return codec.New(supported), supported
+			}
+		}
+	}
+	return nil, MIMEType{}
+}
+
+// problemType returns the MIME type that a problem should be rendered
+// with, when it is marshalled by a codec matched with matched.
+func problemType(matched MIMEType) MIMEType {
+	syntax := matched.Suffix()
+	if syntax == "" {
+		syntax = matched.SubType
+	}
+	switch syntax {
+	case "json", "xml":
+		return MIMEType{
+			Type:    "application",
+			SubType: "problem+" + syntax,
+		}
+	default:
+		return matched
+	}
+}
+
+// marshalProblem marshals p for req, returning the body and its
+// Content-Type.  If no codec is able to marshal p, it falls back to a
+// plain text body.
+func marshalProblem(req *http.Request, p *Problem, codecs []Codec) (body []byte, contentType string) {
+	codec, matched := problemCodec(req, codecs)
+	if marshaler, ok := codec.(ProblemMarshaler); ok {
+		if body, err := marshaler.MarshalProblem(p); err == nil {
+			return body, matched.String()
+		}
+	}
+	if codec != nil {
+		if body, err := codec.Marshal(p); err == nil {
+			return body, problemType(matched).String()
+		}
+	}
+	return []byte(p.Error()), "text/plain; charset=utf-8"
+}
+
+// writeProblemHead writes the headers for p to writer, returning the
+// body that should follow them.
+func writeProblemHead(writer http.ResponseWriter, req *http.Request, p *Problem, codecs []Codec) (body []byte) {
+	if p.Status == 0 {
+		problem := *p
+		problem.Status = http.StatusInternalServerError
+		p = &problem
+	}
+	body, contentType := marshalProblem(req, p, codecs)
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	writer.WriteHeader(p.Status)
+	return body
+}
+
+// WriteProblem writes p to writer as the response to req, using the
+// codec in codecs that best matches req's Accept header.
+func WriteProblem(writer http.ResponseWriter, req *http.Request, p *Problem, codecs []Codec) {
+	body := writeProblemHead(writer, req, p, codecs)
+	writer.Write(body)
+}
+
+// problemf returns a *Problem for status, with a formatted detail.
+func problemf(status int, format string, args ...interface{}) *Problem {
+	return NewProblem(status, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,64 @@
+package silverback_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nelsam/silverback"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Problem", func() {
+	var problem *silverback.Problem
+
+	BeforeEach(func() {
+		problem = silverback.NewProblem(http.StatusForbidden, "You do not have enough credit.")
+		problem.Type = "https://example.com/probs/out-of-credit"
+		problem.Instance = "/account/12345/msgs/abc"
+		problem.Extensions = map[string]interface{}{
+			"balance": 30,
+		}
+	})
+
+	It("uses the status text as its title", func() {
+		Expect(problem.Title).To(Equal("Forbidden"))
+	})
+
+	It("is an error", func() {
+		var err error = problem
+		Expect(err.Error()).To(Equal("Forbidden: You do not have enough credit."))
+		var unwrapped *silverback.Problem
+		Expect(errors.As(fmt.Errorf("wrapped: %w", err), &unwrapped)).To(BeTrue())
+	})
+
+	It("marshals extensions alongside standard members in json", func() {
+		raw, err := json.Marshal(problem)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(raw).To(MatchJSON(`{
+			"type": "https://example.com/probs/out-of-credit",
+			"title": "Forbidden",
+			"status": 403,
+			"detail": "You do not have enough credit.",
+			"instance": "/account/12345/msgs/abc",
+			"balance": 30
+		}`))
+	})
+
+	It("marshals to the RFC 9457 xml format", func() {
+		raw, err := xml.Marshal(problem)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(raw)).To(Equal(`<problem xmlns="urn:ietf:rfc:7807">` +
+			`<type>https://example.com/probs/out-of-credit</type>` +
+			`<title>Forbidden</title>` +
+			`<status>403</status>` +
+			`<detail>You do not have enough credit.</detail>` +
+			`<instance>/account/12345/msgs/abc</instance>` +
+			`<balance>30</balance>` +
+			`</problem>`))
+	})
+})
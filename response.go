@@ -5,6 +5,17 @@ import "net/http"
 // A Response is a container for typical response fields.  We use it
 // mainly so that the handler methods don't need to worry about
 // writing (or, for that matter, marshalling) data.
+//
+// Body may be a stream of items, rather than a single value.  Any
+// channel that can be received from, or any iterator function with
+// the signature func(yield func(T) bool), is treated as a stream.  If
+// the response's codec is a StreamCodec, items will be written to the
+// client as they are produced; otherwise, they will be collected into
+// a slice and marshalled all at once.  If the client disconnects,
+// iteration stops; producers sending on a channel must watch the
+// request's context to know when to stop.  Streams aren't consumed at
+// all for HEAD requests, whose contexts are canceled as soon as the
+// headers have been written.
 type Response struct {
 	Status  int
 	Headers http.Header
@@ -17,6 +28,10 @@ type Response struct {
 	// that behavior.
 	codec Codec
 
+	// contentType is the MIME type that codec was matched with, if
+	// codec was loaded from the Accept header.
+	contentType MIMEType
+
 	// codecs is a slice of codecs available for this Response to use
 	// for formatting data.
 	codecs []Codec
@@ -45,13 +60,50 @@ func NewResponseForCodecs(r *http.Request, codecs []Codec) *Response {
 // Codec returns the codec that will be used for this response.
 func (r *Response) Codec() Codec {
 	if r.codec == nil {
-		accept := ParseAcceptHeader(r.request.Header)
-		r.codec = accept.Codec(r.codecs)
+		r.codec, r.contentType = negotiateCodec(r.request, r.codecs)
 	}
 	return r.codec
 }
 
+// negotiateCodec returns the codec in codecs that best matches req's
+// Accept header, prepared for req if it is a RequestCodec, along with
+// the MIME type that it was matched with.
+func negotiateCodec(req *http.Request, codecs []Codec) (Codec, MIMEType) {
+	accept := ParseAcceptHeader(req.Header)
+	if len(accept) == 0 {
+		// RFC 7231 section 5.3.2: no Accept header means that the
+		// client accepts any media type.
+		accept = Accept{ParseAcceptEntry("*/*")}
+	}
+	codec, matched := accept.bestCodec(codecs)
+	if reqCodec, ok := codec.(RequestCodec); ok {
+		codec = reqCodec.ForRequest(req)
+	}
+	return codec, matched
+}
+
+// ContentType returns the MIME type that Codec() was matched with.
+// If the codec was set using SetCodec, the returned MIMEType will be
+// empty.
+func (r *Response) ContentType() MIMEType {
+	r.Codec()
+	return r.contentType
+}
+
 // SetCodec sets the codec to be used for this response.
 func (r *Response) SetCodec(codec Codec) {
 	r.codec = codec
 }
+
+// status returns r.Status, defaulting to 207 Multi-Status for
+// MultiStatus bodies and 200 OK for everything else if it hasn't been
+// set.
+func (r *Response) status() int {
+	if r.Status == 0 {
+		if _, ok := r.Body.(MultiStatus); ok {
+			return http.StatusMultiStatus
+		}
+		return http.StatusOK
+	}
+	return r.Status
+}
@@ -37,4 +37,17 @@ var _ = Describe("Response", func() {
 	It("loads the matching codec if it hasn't been set", func() {
 		Expect(resp.Codec()).To(Equal(mockJSON))
 	})
+
+	It("responds with concrete content types for wildcard matches", func() {
+		codecs := []silverback.Codec{makeCodec("application", "*+cbor")}
+		for _, accept := range []string{"*/*", "application/*"} {
+			req.Header.Set("Accept", accept)
+			resp = silverback.NewResponseForCodecs(req, codecs)
+			Expect(resp.ContentType().String()).To(Equal("application/cbor"))
+		}
+
+		req.Header.Set("Accept", "application/vnd.foo+cbor")
+		resp = silverback.NewResponseForCodecs(req, codecs)
+		Expect(resp.ContentType().String()).To(Equal("application/vnd.foo+cbor"))
+	})
 })
@@ -1,109 +1,586 @@
 package silverback
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
 
 // A Router is an extension of "github.com/gorilla/mux".Router.
 type Router struct {
-	mux.Router
+	*mux.Router
+	*settings
 
-	codecs []Codec
+	parents []parentRoute
+	route   *routeOptions
+	prefix  string
+}
+
+// settings holds the configuration that a Router shares with its
+// child routers (see Sub).  Groups (see Group) have their own
+// settings, which fall back to their parent's.
+type settings struct {
+	parent     *settings
+	codecList  []Codec
+	heartbeat  time.Duration
+	routes     []RouteInfo
+	middleware []Middleware
+	mapper     ErrorMapper
+	bodyLimit  int64
+
+	languageList []string
+
+	// groups holds every group (see Group) created from the outermost
+	// Router, so that 404s can be rendered with their codecs.
+	groups []*Router
+}
+
+// codecs returns the codecs added to s, or those of its parent if
+// none were.
+func (s *settings) codecs() []Codec {
+	if len(s.codecList) == 0 && s.parent != nil {
+		return s.parent.codecs()
+	}
+	return s.codecList
+}
+
+// eventHeartbeat returns the heartbeat set on s, or on its parent if
+// none was.
+func (s *settings) eventHeartbeat() time.Duration {
+	if s.heartbeat <= 0 && s.parent != nil {
+		return s.parent.eventHeartbeat()
+	}
+	return s.heartbeat
+}
+
+// allMiddleware returns the middleware added to s and its parents,
+// outermost first.
+func (s *settings) allMiddleware() []Middleware {
+	if s.parent == nil {
+		return s.middleware
+	}
+	return append(append([]Middleware(nil), s.parent.allMiddleware()...), s.middleware...)
+}
+
+// root returns the settings of the outermost Router, which holds the
+// route table.
+func (s *settings) root() *settings {
+	for s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// parentRoute is a parent resource of the handlers routed by a child
+// router, along with the route variable holding its identifier.
+type parentRoute struct {
+	handler Handler
+	idVar   string
+}
+
+// parentExists returns whether or not the instance of parent
+// identified by id exists.  Parents that aren't Existers are assumed
+// to exist.
+func (r *Router) parentExists(req *http.Request, parent parentRoute, id string) bool {
+	if _, ok := parent.handler.(Exister); !ok {
+		return true
+	}
+	return r.newHandler(parent.handler, req).(Exister).Exists(id)
 }
 
 func NewRouter() *Router {
-	return &Router{
-		Router: *mux.NewRouter(),
+	r := &Router{
+		Router:   mux.NewRouter(),
+		settings: &settings{},
+	}
+	r.NotFoundHandler = http.HandlerFunc(r.notFound)
+	return r
+}
+
+// notFound responds to requests that don't match any route.
+// The problem is rendered with the codecs of the group (see Group)
+// with the longest prefix that req's path is under, if any.
+func (r *Router) notFound(writer http.ResponseWriter, req *http.Request) {
+	codecs := r.codecs()
+	longest := ""
+	for _, g := range r.root().groups {
+		if len(g.prefix) > len(longest) && underPrefix(req.URL.Path, g.prefix) {
+			longest, codecs = g.prefix, g.codecs()
+		}
+	}
+	WriteProblem(writer, req, problemf(http.StatusNotFound, "No resource found at %s", req.URL.Path), codecs)
+}
+
+// underPrefix returns whether or not urlPath is prefix or a path
+// beneath it.
+func underPrefix(urlPath, prefix string) bool {
+	return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// methods maps HTTP methods to the handlers for those methods.
+type methods map[string]http.Handler
+
+// allowed returns the methods that m allows, including OPTIONS.
+func (m methods) allowed() []string {
+	allowed := make([]string, 0, len(m)+1)
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	allowed = append(allowed, "OPTIONS")
+	sort.Strings(allowed)
+	return allowed
+}
+
+// allowMethods returns an http.Handler that routes requests to the
+// handler in m matching their method.  OPTIONS requests are answered
+// with an Allow header, and requests for any other method receive a
+// 405 Method Not Allowed problem, as required by RFC 9110.
+func (r *Router) allowMethods(m methods) http.Handler {
+	allowed := m.allowed()
+	options := optionsHandler(allowed)
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if h, ok := m[req.Method]; ok {
+			h.ServeHTTP(writer, req)
+			return
+		}
+		if req.Method == "OPTIONS" {
+			options(writer, req)
+			return
+		}
+		writeAllowHeader(allowed, writer)
+		problem := problemf(http.StatusMethodNotAllowed, "%s is not supported by %s", req.Method, req.URL.Path)
+		WriteProblem(writer, req, problem, r.codecs())
+	})
+}
+
+// resourceHandler returns an http.Handler that tags requests with the
+// resource and handler method that they were routed to, then passes
+// them through any middleware (see AddMiddleware) to f.  If any of the
+// resource's parents don't exist, a 404 Not Found problem is written
+// instead of calling f.
+func (r *Router) resourceHandler(handler Handler, method string, f http.HandlerFunc) http.Handler {
+	return r.actionResourceHandler(handler, method, "", f)
+}
+
+// actionResourceHandler is resourceHandler for requests that may be
+// for an action (see Actioner).
+func (r *Router) actionResourceHandler(handler Handler, method, action string, f http.HandlerFunc) http.Handler {
+	itemRoute := isGetter(handler) && !isSingleton(handler)
+	inner := http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		for i, parent := range requestResource(req).Parents {
+			if !r.parentExists(req, r.parents[i], parent.ID) {
+				problem := problemf(http.StatusNotFound, "No resource found at %s", parent.ItemPath(parent.ID))
+				r.respondProblem(writer, req, problem)
+				return
+			}
+		}
+		f(writer, req)
+	})
+	routeMiddleware := r.route.chain(method)
+	h := http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		parents := r.requestParents(req)
+		resourcePath := path.Join("/", r.prefix, handler.Path())
+		if len(parents) > 0 {
+			last := parents[len(parents)-1]
+			resourcePath = path.Join(last.ItemPath(last.ID), handler.Path())
+		}
+		resource := &Resource{
+			Path:      resourcePath,
+			Method:    method,
+			Action:    action,
+			ItemRoute: itemRoute,
+			Parents:   parents,
+			Handler:   handler,
+			router:    r,
+		}
+		if id, key, ok := requestKey(handler, "id", mux.Vars(req)); ok {
+			resource.ID = id
+			resource.Key = key
+		}
+		chained := wrap(routeMiddleware, inner)
+		chained = wrap(r.allMiddleware(), chained)
+		chained.ServeHTTP(writer, withResource(req, resource))
+	})
+	return namedHandler{Handler: h, names: []string{method}}
+}
+
+// requestParents returns the parent resources of a request routed by
+// r, outermost first.
+func (r *Router) requestParents(req *http.Request) []Resource {
+	if len(r.parents) == 0 {
+		return nil
+	}
+	vars := mux.Vars(req)
+	parents := make([]Resource, 0, len(r.parents))
+	prefix := path.Join("/", r.prefix)
+	for _, parent := range r.parents {
+		itemRoute := isGetter(parent.handler) && !isSingleton(parent.handler)
+		id, key, _ := requestKey(parent.handler, parent.idVar, vars)
+		resource := Resource{
+			Path:      path.Join(prefix, parent.handler.Path()),
+			ID:        id,
+			Key:       key,
+			ItemRoute: itemRoute,
+		}
+		parents = append(parents, resource)
+		prefix = resource.ItemPath(resource.ID)
+	}
+	return parents
+}
+
+// routePath returns the route template for a handler path, nested
+// under r's prefix (see Group) and the item paths of r's parents.
+func (r *Router) routePath(handlerPath string) string {
+	if len(r.parents) == 0 && r.prefix == "" {
+		return handlerPath
 	}
+	prefix := path.Join("/", r.prefix)
+	for _, parent := range r.parents {
+		prefix = path.Join(prefix, parent.handler.Path())
+		if !isSingleton(parent.handler) {
+			prefix = path.Join(prefix, idRoute(parent.handler, parent.idVar))
+		}
+	}
+	return path.Join(prefix, handlerPath)
 }
 
 func (r *Router) setupIDPaths(handler Handler) {
-	h := make(handlers.MethodHandler, 5)
-	if _, hasGetter := handler.(Getter); hasGetter {
-		h["GET"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Getter)
-			resp := idHandle(h, h.Get, mux.Vars(req)["id"])
-			WriteResponse(writer, resp, r.codecs)
-		})
-		h["HEAD"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Getter)
-			resp := idHandle(h, h.Get, mux.Vars(req)["id"])
-			WriteHead(writer, resp, r.codecs)
+	h := r.itemMethods(handler)
+	if len(h) > 0 {
+		idRoutePath := path.Join(r.routePath(handler.Path()), idRoute(handler, "id"))
+		r.mount(idRoutePath, handler, "item", "", h)
+	}
+}
+
+// setupSingletonPaths routes the methods of a Singleton handler, which
+// would otherwise be routed to its instances' paths, to its Path().
+func (r *Router) setupSingletonPaths(handler Handler) {
+	h := r.itemMethods(handler)
+	if _, hasPoster := postMethod(handler, nil); hasPoster {
+		h["POST"] = r.postHandler(handler)
+	} else if m, ok := findTypedMethod(handler, "Post"); ok {
+		h["POST"] = r.typedHandler(handler, m, false)
+	}
+	if len(h) > 0 {
+		r.mount(r.routePath(handler.Path()), handler, "singleton", "", h)
+	}
+}
+
+// itemMethods returns the handlers for the methods that handler
+// supports on individual instances of its resource.
+func (r *Router) itemMethods(handler Handler) methods {
+	h := make(methods, 5)
+	var get http.Handler
+	if _, hasGetter := getMethod(handler, nil); hasGetter {
+		get = r.resourceHandler(handler, "Get", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			get, _ := getMethod(h, req)
+			resp := r.idHandle(h, req, false, get, requestResource(req).ID)
+			r.respond(writer, req, resp)
 		})
+		h["HEAD"] = r.resourceHandler(handler, "Get", cancelAfter(func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			get, _ := getMethod(h, req)
+			resp := r.idHandle(h, req, false, get, requestResource(req).ID)
+			r.respondHead(writer, req, resp)
+		}))
+	} else if m, ok := findTypedMethod(handler, "Get"); ok {
+		get = r.typedHandler(handler, m, false)
+		h["HEAD"] = r.typedHandler(handler, m, true)
 	}
-	if _, hasPutter := handler.(Putter); hasPutter {
-		h["PUT"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Putter)
-			resp := idHandle(h, h.Put, mux.Vars(req)["id"])
-			WriteResponse(writer, resp, r.codecs)
+	if _, hasWatcher := handler.(Watcher); hasWatcher {
+		watch := r.resourceHandler(handler, "Watch", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req).(Watcher)
+			resource := requestResource(req)
+			if problem := parseID(h, resource); problem != nil {
+				r.respondProblem(writer, req, problem)
+				return
+			}
+			if err := beforeHandle(h); err != nil {
+				r.respondProblem(writer, req, problemFor(req, err))
+				return
+			}
+			events := h.Watch(resource.ID, req.Header.Get("Last-Event-ID"))
+			r.writeEvents(writer, req, events)
 		})
+		get = r.eventsOr(watch, get)
 	}
-	if _, hasPatcher := handler.(Patcher); hasPatcher {
-		h["PATCH"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Patcher)
-			resp := idHandle(h, h.Patch, mux.Vars(req)["id"])
-			WriteResponse(writer, resp, r.codecs)
+	if get != nil {
+		h["GET"] = get
+	}
+	if _, hasPutter := putMethod(handler, nil); hasPutter {
+		h["PUT"] = r.resourceHandler(handler, "Put", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			put, _ := putMethod(h, req)
+			resp := r.idHandle(h, req, true, put, requestResource(req).ID)
+			r.respond(writer, req, resp)
 		})
+	} else if m, ok := findTypedMethod(handler, "Put"); ok {
+		h["PUT"] = r.typedHandler(handler, m, false)
 	}
-	if _, hasDeleter := handler.(Deleter); hasDeleter {
-		h["DELETE"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Deleter)
-			resp := idHandle(h, h.Delete, mux.Vars(req)["id"])
-			WriteResponse(writer, resp, r.codecs)
+	if _, hasPatcher := patchMethod(handler, nil); hasPatcher {
+		h["PATCH"] = r.resourceHandler(handler, "Patch", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			patch, _ := patchMethod(h, req)
+			resp := r.idHandle(h, req, true, patch, requestResource(req).ID)
+			r.respond(writer, req, resp)
 		})
+	} else if m, ok := findTypedMethod(handler, "Patch"); ok {
+		h["PATCH"] = r.typedHandler(handler, m, false)
 	}
-	if len(h) > 0 {
-		idRoutePath := path.Join(handler.Path(), "{id}")
-		r.Path(idRoutePath).Handler(h)
+	if _, hasDeleter := deleteMethod(handler, nil); hasDeleter {
+		h["DELETE"] = r.resourceHandler(handler, "Delete", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			del, _ := deleteMethod(h, req)
+			resp := r.idHandle(h, req, false, del, requestResource(req).ID)
+			r.respond(writer, req, resp)
+		})
+	} else if m, ok := findTypedMethod(handler, "Delete"); ok {
+		h["DELETE"] = r.typedHandler(handler, m, false)
 	}
+	return h
 }
 
 func (r *Router) setupNonIDPaths(handler Handler) {
-	h := make(handlers.MethodHandler, 3)
-	if _, hasQuerier := handler.(Querier); hasQuerier {
-		h["GET"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Querier)
-			resp := handle(h, h.Query)
-			WriteResponse(writer, resp, r.codecs)
+	h := make(methods, 6)
+	var get http.Handler
+	if _, hasQuerier := queryMethod(handler, nil); hasQuerier {
+		get = r.resourceHandler(handler, "Query", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			query, _ := queryMethod(h, req)
+			resp := r.handle(h, req, false, query)
+			r.respond(writer, req, resp)
+		})
+		h["HEAD"] = r.resourceHandler(handler, "Query", cancelAfter(func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req)
+			query, _ := queryMethod(h, req)
+			resp := r.handle(h, req, false, query)
+			r.respondHead(writer, req, resp)
+		}))
+	} else if m, ok := findTypedMethod(handler, "Query"); ok {
+		get = r.typedHandler(handler, m, false)
+		h["HEAD"] = r.typedHandler(handler, m, true)
+	}
+	if _, hasWatcher := handler.(CollectionWatcher); hasWatcher {
+		watch := r.resourceHandler(handler, "WatchCollection", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req).(CollectionWatcher)
+			if err := beforeHandle(h); err != nil {
+				r.respondProblem(writer, req, problemFor(req, err))
+				return
+			}
+			events := h.WatchCollection(req.Header.Get("Last-Event-ID"))
+			r.writeEvents(writer, req, events)
 		})
-		h["HEAD"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Querier)
-			resp := handle(h, h.Query)
-			WriteHead(writer, resp, r.codecs)
+		get = r.eventsOr(watch, get)
+	}
+	if get != nil {
+		h["GET"] = get
+	}
+	if _, hasPoster := postMethod(handler, nil); hasPoster {
+		h["POST"] = r.postHandler(handler)
+	} else if m, ok := findTypedMethod(handler, "Post"); ok {
+		h["POST"] = r.typedHandler(handler, m, false)
+	}
+	if _, hasPutter := handler.(CollectionPutter); hasPutter {
+		h["PUT"] = r.resourceHandler(handler, "PutCollection", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req).(CollectionPutter)
+			resp := r.handle(h, req, true, h.PutCollection)
+			r.respond(writer, req, resp)
 		})
 	}
-	if _, hasPoster := handler.(Poster); hasPoster {
-		h["POST"] = http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			h := handler.New(req).(Poster)
-			resp := handle(h, h.Post)
-			WriteResponse(writer, resp, r.codecs)
+	if _, hasPatcher := handler.(CollectionPatcher); hasPatcher {
+		h["PATCH"] = r.resourceHandler(handler, "PatchCollection", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req).(CollectionPatcher)
+			resp := r.handle(h, req, true, h.PatchCollection)
+			r.respond(writer, req, resp)
+		})
+	}
+	if _, hasDeleter := handler.(CollectionDeleter); hasDeleter {
+		h["DELETE"] = r.resourceHandler(handler, "DeleteCollection", func(writer http.ResponseWriter, req *http.Request) {
+			h := r.newHandler(handler, req).(CollectionDeleter)
+			resp := r.handle(h, req, false, h.DeleteCollection)
+			r.respond(writer, req, resp)
 		})
 	}
 	if len(h) > 0 {
-		r.Path(handler.Path()).Handler(h)
+		r.mount(r.routePath(handler.Path()), handler, "collection", "", h)
+	}
+}
+
+// setupActionPaths routes POST requests for each of an Actioner's
+// actions to the action's name under the handler's instance paths.
+func (r *Router) setupActionPaths(handler Handler) {
+	actioner, ok := handler.(Actioner)
+	if !ok {
+		return
+	}
+	itemPath := r.routePath(handler.Path())
+	if !isSingleton(handler) {
+		itemPath = path.Join(itemPath, idRoute(handler, "id"))
+	}
+	names := make([]string, 0, len(actioner.Actions()))
+	for name := range actioner.Actions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		post := r.actionHandler(handler, name)
+		r.mount(path.Join(itemPath, name), handler, "action", name, methods{"POST": post})
 	}
 }
 
+// actionHandler returns the handler for POST requests to the action
+// called name.
+func (r *Router) actionHandler(handler Handler, name string) http.Handler {
+	return r.actionResourceHandler(handler, "Action", name, func(writer http.ResponseWriter, req *http.Request) {
+		resource := requestResource(req)
+		h := r.newHandler(handler, req).(Actioner)
+		action, ok := h.Actions()[name]
+		if !ok {
+			r.respondProblem(writer, req, problemf(http.StatusNotFound, "No resource found at %s", req.URL.Path))
+			return
+		}
+		resp := r.idHandle(h, req, true, action, resource.ID)
+		r.respond(writer, req, resp)
+	})
+}
+
+// postHandler returns the handler for POST requests to a Poster or an
+// ErrorPoster.
+func (r *Router) postHandler(handler Handler) http.Handler {
+	return r.resourceHandler(handler, "Post", func(writer http.ResponseWriter, req *http.Request) {
+		h := r.newHandler(handler, req)
+		post, _ := postMethod(h, req)
+		resp := r.handle(h, req, true, func() *Response {
+			return created(h, req, post())
+		})
+		r.respond(writer, req, resp)
+	})
+}
+
 // AddCodec registers a codec with this router.  Any codecs added in
 // this way will be supplied to any *Response value that has not had
-// its codecs set (via NewResponseForCodecs).
+// its codecs set (via NewResponseForCodecs).  Adding codecs to a group
+// (see Group) replaces, rather than extends, the codecs of its parent
+// for the group's routes.
 func (r *Router) AddCodec(codec Codec) {
-	r.codecs = append(r.codecs, codec)
+	r.codecList = append(r.codecList, codec)
+}
+
+// SetEventHeartbeat sets how long event streams (see Watcher) may be
+// idle before a heartbeat comment is sent to keep the connection
+// open.  The default is 15 seconds.
+func (r *Router) SetEventHeartbeat(heartbeat time.Duration) {
+	r.heartbeat = heartbeat
 }
 
 // Route routes the methods on handler to paths, based on handler's
-// Path().
-func (r *Router) Route(handler Handler) {
+// Path().  Options may be used to add middleware for the handler's
+// routes (see AddMiddleware).
+func (r *Router) Route(handler Handler, options ...RouteOption) {
+	if len(options) > 0 {
+		// Routing with a copy of r keeps the options scoped to this
+		// handler's routes.
+		routing := *r
+		routing.route = newRouteOptions(options)
+		r = &routing
+	}
+	// Actions are routed first, so that they take precedence over
+	// identifier templates that could match their paths.
+	r.setupActionPaths(handler)
+	if isSingleton(handler) {
+		r.setupSingletonPaths(handler)
+		return
+	}
 	r.setupIDPaths(handler)
 	r.setupNonIDPaths(handler)
 }
 
+// isSingleton returns whether or not handler is a Singleton that
+// wants to be routed as one.
+func isSingleton(handler Handler) bool {
+	singleton, ok := handler.(Singleton)
+	return ok && singleton.Singleton()
+}
+
+// Sub returns a child router for resources nested under instances of
+// parent.  Handlers routed by the child router have their paths
+// prefixed with parent's item path, so a handler with a Path() of
+// "/posts" routed by r.Sub(users) is served at /users/{id}/posts.
+// The parent's identifier is available to the child handler using
+// RequestResource (see Resource.Parents and Resource.ParentID).
+//
+// If parent is an Exister, requests for the child resources receive a
+// 404 Not Found response, without the child handler being called,
+// when the parent instance doesn't exist.
+//
+// Child routers share their parent's routes, codecs, and settings, so
+// parent itself should still be routed using r.Route.  Sub may be
+// called on a child router for deeper nesting.
+func (r *Router) Sub(parent Handler) *Router {
+	parents := make([]parentRoute, len(r.parents), len(r.parents)+1)
+	copy(parents, r.parents)
+	parents = append(parents, parentRoute{
+		handler: parent,
+		idVar:   "parent" + strconv.Itoa(len(r.parents)),
+	})
+	return &Router{
+		Router:   r.Router,
+		settings: r.settings,
+		parents:  parents,
+		prefix:   r.prefix,
+	}
+}
+
+// Group returns a router for handlers served under prefix, such as an
+// API version ("/v1") or a module mounted by another team
+// ("/billing").  Handlers routed by the group have their paths
+// prefixed, so a handler with a Path() of "/invoices" routed by
+// r.Group("/billing") is served at /billing/invoices; Routes, URLFor,
+// and RequestResource all include the prefix.  The same handler may
+// be routed by several groups.
+//
+// A group uses its parent's codecs and event heartbeat until they are
+// set on the group itself, which overrides them (and so the rendering
+// of problems) for the group's routes only.  Middleware added to the
+// group runs inside any middleware added to its parent.  Requests
+// under prefix that don't match any route receive a 404 Not Found
+// problem rendered with the codecs of the group with the longest
+// matching prefix.  The group shares its parent's route table, so
+// Routes and OpenAPI on the outermost router describe every group.
+//
+// Groups don't claim every request under their prefix: several
+// groups may share a prefix (or nest under one another's prefixes),
+// and handlers routed by r may have paths under prefix.  A prefix of
+// "/" groups handlers without prefixing their paths.
+//
+// Group may be called on a group for nested prefixes.  It panics if
+// called on a child router (see Sub); call Sub on the group instead.
+func (r *Router) Group(prefix string) *Router {
+	if len(r.parents) > 0 {
+		panic("silverback: Group called on a child router")
+	}
+	prefix = path.Join("/", prefix)
+	g := &Router{
+		Router:   r.PathPrefix(prefix).Subrouter(),
+		settings: &settings{parent: r.settings},
+		prefix:   strings.TrimSuffix(path.Join("/", r.prefix, prefix), "/"),
+	}
+	if g.prefix != "" {
+		root := r.settings.root()
+		root.groups = append(root.groups, g)
+	}
+	return g
+}
+
 func optionsHandler(methods []string) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, r *http.Request) {
 		writeAllowHeader(methods, writer)
@@ -117,29 +594,191 @@ func writeAllowHeader(methods []string, writer http.ResponseWriter) {
 	}
 }
 
-func handle(h Handler, f func() *Response) *Response {
-	if before, ok := h.(BeforeHandler); ok {
-		before.BeforeHandle()
+// DefaultMaxBodySize is the largest request body, in bytes, that a
+// Router will read when no limit has been set (see SetMaxBodySize).
+const DefaultMaxBodySize = 10 << 20
+
+// SetMaxBodySize sets the largest request body, in bytes, that r will
+// read for handlers (see BodyReceiver).  Requests with larger bodies
+// receive a 413 Content Too Large problem.  A negative size removes
+// the limit.  Groups (see Group) use their parent's limit unless they
+// set their own.
+func (r *Router) SetMaxBodySize(size int64) {
+	r.bodyLimit = size
+}
+
+// maxBodySize returns the body size limit set on s, or on its parent
+// if none was.
+func (s *settings) maxBodySize() int64 {
+	if s.bodyLimit == 0 {
+		if s.parent != nil {
+			return s.parent.maxBodySize()
+		}
+		return DefaultMaxBodySize
 	}
-	resp := f()
-	if after, ok := h.(AfterHandler); ok {
-		after.AfterHandle(resp)
+	return s.bodyLimit
+}
+
+// readBody unmarshals req's body to h.RequestBody(), if h is a
+// BodyReceiver.  If the body can't be read, it returns a *Problem
+// describing the reason.
+func (r *Router) readBody(h Handler, req *http.Request) *Problem {
+	receiver, ok := h.(BodyReceiver)
+	if !ok {
+		return nil
 	}
-	return resp
+	return r.decodeBody(req, receiver.RequestBody())
+}
+
+// decodeBody unmarshals req's body into target, using the codec
+// matching its Content-Type header.
+func (r *Router) decodeBody(req *http.Request, target interface{}) *Problem {
+	codec := ContentTypeCodec(req.Header, r.codecs())
+	if codec == nil {
+		contentType := req.Header.Get("Content-Type")
+		return problemf(http.StatusUnsupportedMediaType, "Unsupported Content-Type: %q", contentType)
+	}
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	if limit := r.maxBodySize(); limit > 0 {
+		body = http.MaxBytesReader(nil, body, limit)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return problemf(http.StatusRequestEntityTooLarge, "Request body is larger than %d bytes", tooLarge.Limit)
+		}
+		return problemf(http.StatusBadRequest, "Error reading request body: %v", err)
+	}
+	if err := codec.Unmarshal(raw, target); err != nil {
+		return problemf(http.StatusBadRequest, "Error unmarshalling request body: %v", err)
+	}
+	return nil
 }
 
-func idHandle(h Handler, f func(string) *Response, id string) *Response {
+// beforeHandle calls h.BeforeHandle, if h is a BeforeHandler.
+func beforeHandle(h Handler) error {
 	if before, ok := h.(BeforeHandler); ok {
-		before.BeforeHandle()
+		return before.BeforeHandle()
 	}
-	resp := f(id)
+	return nil
+}
+
+// afterHandle records h's links, if h is a Linker, then calls
+// h.AfterHandle, if h is an AfterHandler.  If AfterHandle returns an
+// error, the returned response will be a problem response for that
+// error.
+func afterHandle(h Handler, req *http.Request, resp *Response) *Response {
+	addLinks(h, req)
 	if after, ok := h.(AfterHandler); ok {
-		after.AfterHandle(resp)
+		if err := after.AfterHandle(resp); err != nil {
+			return problemResponse(req, err)
+		}
 	}
 	return resp
 }
 
-func WriteHeaders(writer http.ResponseWriter, resp *Response) {
+// problemResponse returns a *Response for req with a *Problem body
+// describing err.
+func problemResponse(req *http.Request, err error) *Response {
+	resp := NewResponse(req)
+	resp.Body = problemFor(req, err)
+	return resp
+}
+
+// newProblemResponse returns a *Response for req with problem as its
+// body.
+func newProblemResponse(req *http.Request, problem *Problem) *Response {
+	resp := NewResponse(req)
+	resp.Body = problem
+	return resp
+}
+
+// respond writes resp to writer, unless req was interrupted.
+func (r *Router) respond(writer http.ResponseWriter, req *http.Request, resp *Response) {
+	if r.interrupted(writer, req) {
+		return
+	}
+	WriteResponse(writer, resp, r.codecs())
+}
+
+// respondHead is respond for HEAD requests.  Handlers for HEAD
+// requests should be wrapped with cancelAfter, since streamed bodies
+// aren't consumed.
+func (r *Router) respondHead(writer http.ResponseWriter, req *http.Request, resp *Response) {
+	if r.interrupted(writer, req) {
+		return
+	}
+	WriteHead(writer, resp, r.codecs())
+}
+
+// cancelAfter returns f with a request context that is canceled once
+// f returns, so that the producers of streamed bodies that weren't
+// consumed (see Response) stop.
+func cancelAfter(f http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		f(writer, req.WithContext(ctx))
+	}
+}
+
+// respondProblem writes problem to writer, unless req was interrupted.
+func (r *Router) respondProblem(writer http.ResponseWriter, req *http.Request, problem *Problem) {
+	if r.interrupted(writer, req) {
+		return
+	}
+	WriteProblem(writer, req, problem, r.codecs())
+}
+
+// interrupted returns whether or not req's context is done, in which
+// case the response to req shouldn't be written.  Responses to
+// canceled requests (e.g. because the client disconnected) are
+// discarded, since there is no one to read them; when the context's
+// deadline has passed, a 503 Service Unavailable problem is written
+// instead, so that the timeout isn't mistaken for success.
+func (r *Router) interrupted(writer http.ResponseWriter, req *http.Request) bool {
+	err := req.Context().Err()
+	if err == nil {
+		return false
+	}
+	if !errors.Is(err, context.Canceled) {
+		WriteProblem(writer, req, problemf(http.StatusServiceUnavailable, "Timed out handling %s", req.URL.Path), r.codecs())
+	}
+	return true
+}
+
+// handle calls f to handle req with h, after calling BeforeHandle
+// and, if readsBody, reading the request body (see BodyReceiver), so
+// that requests that BeforeHandle rejects are never read.
+func (r *Router) handle(h Handler, req *http.Request, readsBody bool, f func() *Response) *Response {
+	if err := beforeHandle(h); err != nil {
+		return problemResponse(req, err)
+	}
+	if readsBody {
+		if problem := r.readBody(h, req); problem != nil {
+			return newProblemResponse(req, problem)
+		}
+	}
+	return afterHandle(h, req, f())
+}
+
+// idHandle is handle for handler methods that take an identifier,
+// which is parsed (see IDParser) before anything else.
+func (r *Router) idHandle(h Handler, req *http.Request, readsBody bool, f func(string) *Response, id string) *Response {
+	if problem := parseID(h, requestResource(req)); problem != nil {
+		return newProblemResponse(req, problem)
+	}
+	return r.handle(h, req, readsBody, func() *Response {
+		return f(id)
+	})
+}
+
+// copyHeaders adds resp.Headers to writer's headers.
+func copyHeaders(writer http.ResponseWriter, resp *Response) {
 	for name, values := range resp.Headers {
 		for _, v := range values {
 			writer.Header().Add(name, v)
@@ -147,31 +786,112 @@ func WriteHeaders(writer http.ResponseWriter, resp *Response) {
 	}
 }
 
+// WriteHeaders writes resp.Headers to writer, along with a
+// Content-Type header for resp's codec if resp.Headers doesn't
+// include one.
+func WriteHeaders(writer http.ResponseWriter, resp *Response) {
+	copyHeaders(writer, resp)
+	if writer.Header().Get("Content-Type") != "" {
+		return
+	}
+	if contentType := resp.ContentType(); contentType.Type != "" {
+		writer.Header().Set("Content-Type", contentType.String())
+	}
+}
+
+// WriteHead writes the status and headers for resp to writer, and
+// returns the marshalled body that should follow them.  It is used
+// directly for HEAD requests, which share GET's status and headers
+// but have no body.
+//
+// If resp.Body is a *Problem, or the response can't be marshalled
+// (including when no codec matches the request's Accept header), a
+// problem response is written instead.
 func WriteHead(writer http.ResponseWriter, resp *Response, codecs []Codec) (body []byte) {
-	WriteHeaders(writer, resp)
-	writer.WriteHeader(resp.Status)
 	if resp.codecs == nil {
 		resp.codecs = codecs
 	}
-	body, err := resp.Codec().Marshal(resp.Body)
+	if problem, ok := resp.Body.(*Problem); ok {
+		copyHeaders(writer, resp)
+		if problem.Status == 0 {
+			// Don't modify the handler's problem, which may be shared.
+			p := *problem
+			p.Status = resp.Status
+			problem = &p
+		}
+		return writeProblemHead(writer, resp.request, problem, resp.codecs)
+	}
+	if isStream(resp.Body) {
+		// There's no way to know the length of a stream without
+		// consuming it, so streamed bodies are only consumed when
+		// they're actually written.
+		WriteHeaders(writer, resp)
+		writer.WriteHeader(resp.status())
+		return nil
+	}
+	if status := resp.status(); status == http.StatusNoContent || status == http.StatusNotModified {
+		// These responses can't have a body.
+		copyHeaders(writer, resp)
+		writer.WriteHeader(status)
+		return nil
+	}
+	codec := resp.Codec()
+	if codec == nil {
+		problem := problemf(http.StatusNotAcceptable, "No supported media type matches %q", resp.request.Header.Get("Accept"))
+		copyHeaders(writer, resp)
+		return writeProblemHead(writer, resp.request, problem, resp.codecs)
+	}
+	body, err := codec.Marshal(resp.Body)
 	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		msg := fmt.Sprintf("Error marshalling data: %v", err)
-		writer.Write([]byte(msg))
-		return
+		// Codecs may return a *Problem when the request itself is
+		// the reason that the body can't be marshalled; other errors
+		// may describe server internals, so they're left to the
+		// ErrorMapper.
+		return writeProblemHead(writer, resp.request, problemFor(resp.request, err), resp.codecs)
 	}
+	WriteHeaders(writer, resp)
 	writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
-	if resp.Status == http.StatusOK {
-		// For HEAD requests, the norm is StatusNoContent; however, we
-		// don't want to overwrite error states, redirects, or other
-		// statuses.  To avoid that, we only overwrite StatusOK.
-		resp.Status = http.StatusNoContent
-	}
-	writer.WriteHeader(resp.Status)
+	writer.WriteHeader(resp.status())
 	return body
 }
 
+// WriteResponse writes resp to writer, marshalling resp.Body using
+// resp's codec.
 func WriteResponse(writer http.ResponseWriter, resp *Response, codecs []Codec) {
+	if resp.codecs == nil {
+		resp.codecs = codecs
+	}
+	if isStream(resp.Body) {
+		if codec, ok := resp.Codec().(StreamCodec); ok {
+			writeStream(writer, resp, codec)
+			return
+		}
+		resp.Body = collect(resp.request.Context(), resp.Body)
+	}
 	body := WriteHead(writer, resp, codecs)
 	writer.Write(body)
 }
+
+// writeStream writes the items in resp.Body to writer as they are
+// received, flushing after each item.  It stops early if the client
+// disconnects or an item can't be written.
+func writeStream(writer http.ResponseWriter, resp *Response, codec StreamCodec) {
+	WriteHeaders(writer, resp)
+	writer.WriteHeader(resp.status())
+	flusher, _ := writer.(http.Flusher)
+	eachItem(resp.request.Context(), resp.Body, func(item interface{}) bool {
+		body, err := codec.MarshalItem(item)
+		if err != nil {
+			// The status has already been written, so all we can do
+			// is stop writing.
+			return false
+		}
+		if _, err := writer.Write(body); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	})
+}
@@ -0,0 +1,1743 @@
+package silverback_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type widget struct {
+	Name  string
+	Count int
+}
+
+type widgetHandler struct {
+	req    *http.Request
+	body   widget
+	posted chan widget
+}
+
+func (h *widgetHandler) New(r *http.Request) silverback.Handler {
+	return &widgetHandler{req: r, posted: h.posted}
+}
+
+func (h *widgetHandler) Path() string {
+	return "/widgets"
+}
+
+func (h *widgetHandler) BeforeHandle() error {
+	if h.req.Header.Get("Authorization") == "deny" {
+		return silverback.NewProblem(http.StatusUnauthorized, "Access denied")
+	}
+	return nil
+}
+
+func (h *widgetHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *widgetHandler) Post() *silverback.Response {
+	h.posted <- h.body
+	resp := silverback.NewResponse(h.req)
+	resp.Status = http.StatusOK
+	resp.Headers = http.Header{"Cache-Control": {"no-store"}}
+	resp.Body = h.body
+	return resp
+}
+
+type streamHandler struct {
+	req  *http.Request
+	body func(ctx context.Context) interface{}
+}
+
+func (h *streamHandler) New(r *http.Request) silverback.Handler {
+	return &streamHandler{req: r, body: h.body}
+}
+
+func (h *streamHandler) Path() string {
+	return "/rows"
+}
+
+func (h *streamHandler) Query() *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Status = http.StatusOK
+	resp.Body = h.body(h.req.Context())
+	return resp
+}
+
+type watchHandler struct {
+	req    *http.Request
+	events []silverback.Event
+	delay  time.Duration
+	lastID chan string
+}
+
+func (h *watchHandler) New(r *http.Request) silverback.Handler {
+	return &watchHandler{req: r, events: h.events, delay: h.delay, lastID: h.lastID}
+}
+
+func (h *watchHandler) Path() string {
+	return "/feeds"
+}
+
+func (h *watchHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Status = http.StatusOK
+	resp.Body = widget{Name: id}
+	return resp
+}
+
+func (h *watchHandler) Watch(id, lastEventID string) <-chan silverback.Event {
+	h.lastID <- lastEventID
+	events := make(chan silverback.Event)
+	go func() {
+		defer close(events)
+		time.Sleep(h.delay)
+		for _, event := range h.events {
+			select {
+			case events <- event:
+			case <-h.req.Context().Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+type report struct {
+	ID        string
+	Language  string
+	Principal interface{}
+	Codec     string
+	Vars      map[string]string
+}
+
+type reportHandler struct {
+	ctx     context.Context
+	started chan bool
+}
+
+func (h *reportHandler) New(r *http.Request) silverback.Handler {
+	panic("reportHandler should be constructed with NewContext")
+}
+
+func (h *reportHandler) NewContext(ctx context.Context) silverback.Handler {
+	return &reportHandler{ctx: ctx, started: h.started}
+}
+
+func (h *reportHandler) Path() string {
+	return "/reports"
+}
+
+func (h *reportHandler) Get(id string) *silverback.Response {
+	if id == "slow" {
+		h.started <- true
+		<-h.ctx.Done()
+	}
+	_, mime := silverback.ResponseCodec(h.ctx)
+	resource, _ := silverback.ResourceFromContext(h.ctx)
+	resp := silverback.NewResponse(silverback.RequestFromContext(h.ctx))
+	resp.Body = report{
+		ID:        resource.ID,
+		Language:  silverback.Language(h.ctx),
+		Principal: silverback.Principal(h.ctx),
+		Codec:     mime.String(),
+		Vars:      silverback.RouteVars(h.ctx),
+	}
+	return resp
+}
+
+type contact struct {
+	Key  string
+	Name string
+}
+
+func (c contact) ID() string {
+	return c.Key
+}
+
+type contactInput struct {
+	Name string
+}
+
+type contactHandler struct {
+	req *http.Request
+}
+
+var (
+	_ silverback.TypedGetter[contact]                 = (*contactHandler)(nil)
+	_ silverback.TypedQuerier[[]contact]              = (*contactHandler)(nil)
+	_ silverback.TypedPoster[contactInput, contact]   = (*contactHandler)(nil)
+	_ silverback.TypedPatcher[contactInput, *contact] = (*contactHandler)(nil)
+	_ silverback.TypedDeleter                         = (*contactHandler)(nil)
+)
+
+func (h *contactHandler) New(r *http.Request) silverback.Handler {
+	return &contactHandler{req: r}
+}
+
+func (h *contactHandler) BeforeHandle() error {
+	if h.req.Header.Get("Authorization") == "deny" {
+		return silverback.NewProblem(http.StatusUnauthorized, "Access denied")
+	}
+	return nil
+}
+
+func (h *contactHandler) Path() string {
+	return "/contacts"
+}
+
+func (h *contactHandler) Get(ctx context.Context, id string) (contact, error) {
+	if id == "0" {
+		return contact{}, silverback.NewProblem(http.StatusNotFound, "No such contact")
+	}
+	return contact{Key: id, Name: "Ada"}, nil
+}
+
+func (h *contactHandler) Query(ctx context.Context) ([]contact, error) {
+	return []contact{{Key: "1", Name: "Ada"}}, nil
+}
+
+func (h *contactHandler) Post(ctx context.Context, in contactInput) (contact, error) {
+	return contact{Key: "9", Name: in.Name}, nil
+}
+
+func (h *contactHandler) Patch(ctx context.Context, id string, in contactInput) (*contact, error) {
+	return &contact{Key: id, Name: in.Name}, nil
+}
+
+func (h *contactHandler) Delete(ctx context.Context, id string) error {
+	if id == "0" {
+		return errors.New("contact is locked")
+	}
+	return nil
+}
+
+var errPaymentRequired = errors.New("payment required")
+
+type order struct {
+	Key  string
+	Item string
+}
+
+type orderHandler struct {
+	req  *http.Request
+	body order
+}
+
+var (
+	_ silverback.ErrorGetter  = (*orderHandler)(nil)
+	_ silverback.ErrorPoster  = (*orderHandler)(nil)
+	_ silverback.ErrorDeleter = (*orderHandler)(nil)
+)
+
+func (h *orderHandler) New(r *http.Request) silverback.Handler {
+	return &orderHandler{req: r}
+}
+
+func (h *orderHandler) Path() string {
+	return "/orders"
+}
+
+func (h *orderHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *orderHandler) Get(id string) (*silverback.Response, error) {
+	switch id {
+	case "missing":
+		return nil, fmt.Errorf("order %s: %w", id, silverback.ErrNotFound)
+	case "unpaid":
+		return nil, errPaymentRequired
+	}
+	resp := silverback.NewResponse(h.req)
+	resp.Body = order{Key: id, Item: "book"}
+	return resp, nil
+}
+
+func (h *orderHandler) Post() (*silverback.Response, error) {
+	if h.body.Item == "" {
+		return nil, silverback.Invalid("Item", "must not be empty")
+	}
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.body
+	return resp, nil
+}
+
+func (h *orderHandler) Delete(id string) (*silverback.Response, error) {
+	if id == "shipped" {
+		return nil, silverback.ErrConflict
+	}
+	return nil, nil
+}
+
+type userHandler struct {
+	req   *http.Request
+	users map[string]bool
+}
+
+func (h *userHandler) New(r *http.Request) silverback.Handler {
+	return &userHandler{req: r, users: h.users}
+}
+
+func (h *userHandler) Path() string {
+	return "/users"
+}
+
+func (h *userHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: id}
+	return resp
+}
+
+func (h *userHandler) Exists(id string) bool {
+	return h.users[id]
+}
+
+type post struct {
+	User string
+	ID   string
+	Path string
+}
+
+type postHandler struct {
+	req    *http.Request
+	called chan bool
+}
+
+func (h *postHandler) New(r *http.Request) silverback.Handler {
+	return &postHandler{req: r, called: h.called}
+}
+
+func (h *postHandler) Path() string {
+	return "/posts"
+}
+
+func (h *postHandler) Get(id string) *silverback.Response {
+	h.called <- true
+	resource, _ := silverback.RequestResource(h.req)
+	resp := silverback.NewResponse(h.req)
+	resp.Body = post{User: resource.ParentID(), ID: id, Path: resource.ItemPath(id)}
+	return resp
+}
+
+func (h *postHandler) Query() *silverback.Response {
+	h.called <- true
+	resource, _ := silverback.RequestResource(h.req)
+	resp := silverback.NewResponse(h.req)
+	resp.Body = []post{{User: resource.ParentID(), Path: resource.CollectionPath()}}
+	return resp
+}
+
+type ticketHandler struct {
+	req *http.Request
+	id  int
+}
+
+func (h *ticketHandler) New(r *http.Request) silverback.Handler {
+	return &ticketHandler{req: r}
+}
+
+func (h *ticketHandler) Path() string {
+	return "/tickets"
+}
+
+func (h *ticketHandler) IDPattern() string {
+	return silverback.NumericID
+}
+
+func (h *ticketHandler) ParseID(id string) error {
+	var err error
+	h.id, err = strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	if h.id > 1000 {
+		return silverback.NewProblem(http.StatusNotFound, "No such ticket")
+	}
+	return nil
+}
+
+func (h *ticketHandler) Get(string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: "ticket", Count: h.id}
+	return resp
+}
+
+type rate struct {
+	Currency string
+	Date     string
+	ID       string
+}
+
+type rateHandler struct {
+	req *http.Request
+	key rate
+}
+
+func (h *rateHandler) New(r *http.Request) silverback.Handler {
+	return &rateHandler{req: r}
+}
+
+func (h *rateHandler) Path() string {
+	return "/rates"
+}
+
+func (h *rateHandler) IDTemplate() string {
+	return "{currency:[A-Z]{3}}/{date}"
+}
+
+func (h *rateHandler) ParseKey(key silverback.Key) error {
+	h.key = rate{Currency: key["currency"], Date: key["date"]}
+	return nil
+}
+
+func (h *rateHandler) Get(id string) *silverback.Response {
+	h.key.ID = id
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.key
+	return resp
+}
+
+type fileHandler struct {
+	req *http.Request
+}
+
+func (h *fileHandler) New(r *http.Request) silverback.Handler {
+	return &fileHandler{req: r}
+}
+
+func (h *fileHandler) Path() string {
+	return "/files"
+}
+
+func (h *fileHandler) IDTemplate() string {
+	return "{path:.+}"
+}
+
+func (h *fileHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: id}
+	return resp
+}
+
+type settingsHandler struct {
+	req  *http.Request
+	body widget
+	id   chan string
+}
+
+func (h *settingsHandler) New(r *http.Request) silverback.Handler {
+	return &settingsHandler{req: r, id: h.id}
+}
+
+func (h *settingsHandler) Path() string {
+	return "/settings"
+}
+
+func (h *settingsHandler) Singleton() bool {
+	return true
+}
+
+func (h *settingsHandler) BeforeHandle() error {
+	if h.req.Header.Get("Authorization") == "deny" {
+		return silverback.NewProblem(http.StatusUnauthorized, "Access denied")
+	}
+	return nil
+}
+
+func (h *settingsHandler) AfterHandle(resp *silverback.Response) error {
+	if resp.Headers == nil {
+		resp.Headers = make(http.Header)
+	}
+	resp.Headers.Set("X-Settings", "true")
+	return nil
+}
+
+func (h *settingsHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *settingsHandler) Get(id string) *silverback.Response {
+	h.id <- id
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: "settings"}
+	return resp
+}
+
+func (h *settingsHandler) Put(id string) *silverback.Response {
+	h.id <- id
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.body
+	return resp
+}
+
+type jobHandler struct {
+	req  *http.Request
+	body widget
+}
+
+func (h *jobHandler) New(r *http.Request) silverback.Handler {
+	return &jobHandler{req: r}
+}
+
+func (h *jobHandler) Path() string {
+	return "/jobs"
+}
+
+func (h *jobHandler) BeforeHandle() error {
+	if h.req.Header.Get("Authorization") == "deny" {
+		return silverback.NewProblem(http.StatusUnauthorized, "Access denied")
+	}
+	return nil
+}
+
+func (h *jobHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *jobHandler) Get(id string) *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: id}
+	return resp
+}
+
+func (h *jobHandler) Actions() map[string]silverback.Action {
+	return map[string]silverback.Action{
+		"retry":  h.retry,
+		"cancel": h.retry,
+	}
+}
+
+func (h *jobHandler) retry(id string) *silverback.Response {
+	resource, _ := silverback.RequestResource(h.req)
+	resp := silverback.NewResponse(h.req)
+	resp.Body = widget{Name: id + " " + resource.Action + " " + h.body.Name}
+	return resp
+}
+
+type bulkHandler struct {
+	req  *http.Request
+	body []widget
+}
+
+func (h *bulkHandler) New(r *http.Request) silverback.Handler {
+	return &bulkHandler{req: r}
+}
+
+func (h *bulkHandler) Path() string {
+	return "/bulk"
+}
+
+func (h *bulkHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *bulkHandler) PutCollection() *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.body
+	return resp
+}
+
+func (h *bulkHandler) PatchCollection() *silverback.Response {
+	var results silverback.MultiStatus
+	for _, w := range h.body {
+		if w.Count < 0 {
+			results = append(results, silverback.ItemProblem(w.Name, silverback.NewProblem(http.StatusUnprocessableEntity, "Negative count")))
+			continue
+		}
+		results = append(results, silverback.ItemOK(w.Name, 0, w))
+	}
+	resp := silverback.NewResponse(h.req)
+	resp.Body = results
+	return resp
+}
+
+func (h *bulkHandler) DeleteCollection() *silverback.Response {
+	name := h.req.URL.Query().Get("name")
+	resp := silverback.NewResponse(h.req)
+	resp.Body = silverback.MultiStatus{silverback.ItemOK(name, http.StatusNoContent, nil)}
+	return resp
+}
+
+type account struct {
+	AccountID string
+}
+
+func (a account) ID() string {
+	return a.AccountID
+}
+
+type accountHandler struct {
+	req     *http.Request
+	body    account
+	status  int
+	created string
+}
+
+func (h *accountHandler) New(r *http.Request) silverback.Handler {
+	return &accountHandler{req: r, status: h.status, created: h.created}
+}
+
+func (h *accountHandler) Path() string {
+	return "/accounts"
+}
+
+func (h *accountHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *accountHandler) Get(id string) *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+func (h *accountHandler) Post() *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Status = h.status
+	resp.Body = h.body
+	return resp
+}
+
+type queuedAccountHandler struct {
+	*accountHandler
+}
+
+func (h *queuedAccountHandler) New(r *http.Request) silverback.Handler {
+	return &queuedAccountHandler{h.accountHandler.New(r).(*accountHandler)}
+}
+
+func (h *queuedAccountHandler) CreatedID() string {
+	return h.created
+}
+
+type ledgerHandler struct {
+	req  *http.Request
+	body account
+}
+
+func (h *ledgerHandler) New(r *http.Request) silverback.Handler {
+	return &ledgerHandler{req: r}
+}
+
+func (h *ledgerHandler) Path() string {
+	return "/ledger"
+}
+
+func (h *ledgerHandler) RequestBody() interface{} {
+	return &h.body
+}
+
+func (h *ledgerHandler) Post() *silverback.Response {
+	resp := silverback.NewResponse(h.req)
+	resp.Body = h.body
+	return resp
+}
+
+type commentHandler struct {
+	*postHandler
+}
+
+func (h *commentHandler) New(r *http.Request) silverback.Handler {
+	return &commentHandler{h.postHandler.New(r).(*postHandler)}
+}
+
+func (h *commentHandler) Path() string {
+	return "/comments"
+}
+
+var _ = Describe("Router", func() {
+	var (
+		router  *silverback.Router
+		handler *widgetHandler
+		gob     *codecs.Gob
+	)
+
+	BeforeEach(func() {
+		gob = &codecs.Gob{}
+		handler = &widgetHandler{posted: make(chan widget, 1)}
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSON{})
+		router.AddCodec(gob)
+		router.Route(handler)
+	})
+
+	Context("Request Bodies", func() {
+		It("decodes request bodies using the Content-Type codec", func() {
+			raw, err := gob.Marshal(widget{Name: "foo", Count: 2})
+			Expect(err).ToNot(HaveOccurred())
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewReader(raw))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/x-gob")
+			req.Header.Set("Accept", "application/x-gob")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(handler.posted).To(Receive(Equal(widget{Name: "foo", Count: 2})))
+
+			var actual widget
+			Expect(gob.Unmarshal(recorder.Body.Bytes(), &actual)).To(Succeed())
+			Expect(actual).To(Equal(widget{Name: "foo", Count: 2}))
+		})
+
+		It("responds with 415 for unsupported Content-Types", func() {
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "text/plain")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(handler.posted).ToNot(Receive())
+		})
+
+		It("responds with 400 for malformed bodies", func() {
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString("{"))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(handler.posted).ToNot(Receive())
+		})
+
+		It("calls BeforeHandle before reading bodies", func() {
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set("Authorization", "deny")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("responds with 413 for bodies over the size limit", func() {
+			router.SetMaxBodySize(8)
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString(`{"Name":"a long name"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(handler.posted).ToNot(Receive())
+		})
+	})
+
+	Context("Streamed Bodies", func() {
+		var rows *streamHandler
+
+		BeforeEach(func() {
+			rows = &streamHandler{}
+			router.AddCodec(&codecs.NDJSON{})
+			router.Route(rows)
+		})
+
+		query := func(accept string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("GET", "/rows", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", accept)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		It("streams channel bodies one line per item", func() {
+			rows.body = func(context.Context) interface{} {
+				ch := make(chan widget, 2)
+				ch <- widget{Name: "foo"}
+				ch <- widget{Name: "bar"}
+				close(ch)
+				return ch
+			}
+			recorder := query("application/x-ndjson")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Flushed).To(BeTrue())
+			Expect(recorder.Body.String()).To(Equal("{\"Name\":\"foo\",\"Count\":0}\n{\"Name\":\"bar\",\"Count\":0}\n"))
+		})
+
+		It("streams iterator bodies one line per item", func() {
+			rows.body = func(context.Context) interface{} {
+				return func(yield func(int) bool) {
+					for i := 0; i < 3; i++ {
+						if !yield(i) {
+							return
+						}
+					}
+				}
+			}
+			Expect(query("application/x-ndjson").Body.String()).To(Equal("0\n1\n2\n"))
+		})
+
+		It("collects streams for codecs that can't stream", func() {
+			rows.body = func(context.Context) interface{} {
+				return func(yield func(int) bool) {
+					for i := 0; i < 3; i++ {
+						if !yield(i) {
+							return
+						}
+					}
+				}
+			}
+			Expect(query("application/json").Body.String()).To(MatchJSON("[0, 1, 2]"))
+		})
+
+		It("stops iterating when the client disconnects", func() {
+			stopped := make(chan int, 1)
+			ctx, cancel := context.WithCancel(context.Background())
+			rows.body = func(context.Context) interface{} {
+				return func(yield func(int) bool) {
+					i := 0
+					for ; yield(i); i++ {
+						if i == 2 {
+							cancel()
+						}
+					}
+					stopped <- i
+				}
+			}
+			req, err := http.NewRequest("GET", "/rows", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req = req.WithContext(ctx)
+			req.Header.Set("Accept", "application/x-ndjson")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			Expect(stopped).To(Receive(Equal(3)))
+		})
+
+		It("stops channel producers after responding to HEAD requests", func() {
+			stopped := make(chan struct{})
+			rows.body = func(ctx context.Context) interface{} {
+				ch := make(chan int)
+				go func() {
+					defer close(stopped)
+					for i := 0; ; i++ {
+						select {
+						case ch <- i:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return ch
+			}
+			req, err := http.NewRequest("HEAD", "/rows", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/x-ndjson")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.Len()).To(BeZero())
+			Eventually(stopped).Should(BeClosed())
+		})
+	})
+
+	Context("Event Streams", func() {
+		var feeds *watchHandler
+
+		BeforeEach(func() {
+			feeds = &watchHandler{lastID: make(chan string, 1)}
+			router.Route(feeds)
+		})
+
+		watch := func(accept string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("GET", "/feeds/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", accept)
+			req.Header.Set("Last-Event-ID", "41")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		It("serves events to clients that prefer text/event-stream", func() {
+			feeds.events = []silverback.Event{
+				{ID: "42", Event: "update", Retry: time.Second, Data: widget{Name: "foo", Count: 1}},
+				{Data: "multi\nline"},
+			}
+			recorder := watch("text/event-stream, application/json; q=0.5")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+			Expect(feeds.lastID).To(Receive(Equal("41")))
+			expected := "id: 42\nevent: update\nretry: 1000\ndata: {\"Name\":\"foo\",\"Count\":1}\n\n" +
+				"data: \"multi\\nline\"\n\n"
+			Expect(recorder.Body.String()).To(Equal(expected))
+		})
+
+		It("sends heartbeats while the stream is idle", func() {
+			router.SetEventHeartbeat(5 * time.Millisecond)
+			feeds.delay = 30 * time.Millisecond
+			feeds.events = []silverback.Event{{Data: 1}}
+			body := watch("text/event-stream").Body.String()
+			Expect(body).To(HavePrefix(":\n\n"))
+			Expect(body).To(HaveSuffix("data: 1\n\n"))
+		})
+
+		It("falls back to the Getter for other clients", func() {
+			recorder := watch("application/json, text/event-stream; q=0.5")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"foo","Count":0}`))
+			Expect(feeds.lastID).ToNot(Receive())
+		})
+	})
+
+	Context("Problems", func() {
+		serve := func(method, path, accept string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		problemBody := func(recorder *httptest.ResponseRecorder) map[string]interface{} {
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			var body map[string]interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			return body
+		}
+
+		It("responds to unknown paths with a 404 problem", func() {
+			recorder := serve("GET", "/nothing", "")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(problemBody(recorder)).To(HaveKeyWithValue("status", BeEquivalentTo(http.StatusNotFound)))
+		})
+
+		It("responds to unsupported methods with a 405 problem and Allow header", func() {
+			recorder := serve("DELETE", "/widgets", "")
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"OPTIONS", "POST"}))
+			Expect(problemBody(recorder)).To(HaveKeyWithValue("title", "Method Not Allowed"))
+		})
+
+		It("responds to OPTIONS with an Allow header", func() {
+			recorder := serve("OPTIONS", "/widgets", "")
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"OPTIONS", "POST"}))
+		})
+
+		It("responds with a 406 problem when nothing matches the Accept header", func() {
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString(`{"Name":"foo"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "image/png")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusNotAcceptable))
+			Expect(problemBody(recorder)).To(HaveKeyWithValue("status", BeEquivalentTo(http.StatusNotAcceptable)))
+			Expect(recorder.Header().Get("Cache-Control")).To(Equal("no-store"))
+		})
+
+		It("renders errors returned from BeforeHandle", func() {
+			req, err := http.NewRequest("POST", "/widgets", bytes.NewBufferString(`{"Name":"foo"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "deny")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(problemBody(recorder)).To(HaveKeyWithValue("detail", "Access denied"))
+			Expect(handler.posted).ToNot(Receive())
+		})
+	})
+
+	Context("Sub-resources", func() {
+		var posts *postHandler
+
+		get := func(path string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			users := &userHandler{users: map[string]bool{"42": true}}
+			posts = &postHandler{called: make(chan bool, 1)}
+			router.Route(users)
+			router.Sub(users).Route(posts)
+		})
+
+		It("routes nested items with their parent IDs", func() {
+			recorder := get("/users/42/posts/7")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"User":"42","ID":"7","Path":"/users/42/posts/7"}`))
+		})
+
+		It("routes nested collections", func() {
+			recorder := get("/users/42/posts")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"User":"42","ID":"","Path":"/users/42/posts"}]`))
+		})
+
+		It("still routes the parent resource", func() {
+			recorder := get("/users/42")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(posts.called).ToNot(Receive())
+		})
+
+		It("responds with 404 before calling the child when the parent doesn't exist", func() {
+			recorder := get("/users/13/posts/7")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(recorder.Body.String()).To(ContainSubstring("/users/13"))
+			Expect(posts.called).ToNot(Receive())
+		})
+
+		It("supports deeper nesting", func() {
+			comments := &postHandler{called: make(chan bool, 1)}
+			router.Sub(&userHandler{users: map[string]bool{"42": true}}).Sub(posts).Route(&commentHandler{comments})
+			recorder := get("/users/42/posts/7/comments/1")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"User":"7","ID":"1","Path":"/users/42/posts/7/comments/1"}`))
+		})
+	})
+
+	Context("Identifiers", func() {
+		get := func(path string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			router.Route(&ticketHandler{})
+		})
+
+		It("passes parsed identifiers to handler methods", func() {
+			recorder := get("/tickets/12")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"ticket","Count":12}`))
+		})
+
+		It("responds with 404 for identifiers that don't match the pattern", func() {
+			Expect(get("/tickets/abc").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("responds with 400 when the identifier can't be parsed", func() {
+			recorder := get("/tickets/99999999999999999999999")
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		})
+
+		It("responds with problems returned from ParseID", func() {
+			recorder := get("/tickets/5000")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Body.String()).To(ContainSubstring("No such ticket"))
+		})
+
+		It("passes composite keys and identifiers to handlers", func() {
+			router.Route(&rateHandler{})
+			recorder := get("/rates/USD/2024-01-01")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Currency":"USD","Date":"2024-01-01","ID":"USD/2024-01-01"}`))
+			Expect(get("/rates/usd/2024-01-01").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("supports identifiers containing slashes", func() {
+			router.Route(&fileHandler{})
+			recorder := get("/files/docs/readme.md")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"docs/readme.md","Count":0}`))
+		})
+
+		It("nests resources under composite parents", func() {
+			router.Sub(&rateHandler{}).Route(&postHandler{called: make(chan bool, 1)})
+			recorder := get("/rates/USD/2024-01-01/posts/1")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"User":"USD/2024-01-01","ID":"1","Path":"/rates/USD/2024-01-01/posts/1"}`))
+		})
+
+		It("applies parent patterns to nested routes", func() {
+			router.Sub(&ticketHandler{}).Route(&postHandler{called: make(chan bool, 1)})
+			Expect(get("/tickets/12/posts/1").Code).To(Equal(http.StatusOK))
+			Expect(get("/tickets/abc/posts/1").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Singletons", func() {
+		var settings *settingsHandler
+
+		serve := func(method, path, auth string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, bytes.NewBufferString(`{"Name":"updated"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", auth)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			settings = &settingsHandler{id: make(chan string, 1)}
+			router.Route(settings)
+		})
+
+		It("routes item methods to the handler's path", func() {
+			recorder := serve("GET", "/settings", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"settings","Count":0}`))
+			Expect(recorder.Header().Get("X-Settings")).To(Equal("true"))
+			Expect(settings.id).To(Receive(Equal("")))
+
+			recorder = serve("PUT", "/settings", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"updated","Count":0}`))
+		})
+
+		It("doesn't route instance paths", func() {
+			Expect(serve("GET", "/settings/1", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("computes the Allow header from the singleton's methods", func() {
+			recorder := serve("POST", "/settings", "")
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"GET", "HEAD", "OPTIONS", "PUT"}))
+		})
+
+		It("calls BeforeHandle", func() {
+			Expect(serve("GET", "/settings", "deny").Code).To(Equal(http.StatusUnauthorized))
+			Expect(settings.id).ToNot(Receive())
+		})
+
+		It("can be the parent of nested resources", func() {
+			router.Sub(settings).Route(&postHandler{called: make(chan bool, 1)})
+			recorder := serve("GET", "/settings/posts/1", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"User":"","ID":"1","Path":"/settings/posts/1"}`))
+		})
+	})
+
+	Context("Actions", func() {
+		serve := func(method, path, auth string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, bytes.NewBufferString(`{"Name":"now"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", auth)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			router.Route(&jobHandler{})
+		})
+
+		It("routes POST requests to actions under the instance path", func() {
+			recorder := serve("POST", "/jobs/7/retry", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"7 retry now","Count":0}`))
+
+			recorder = serve("POST", "/jobs/7/cancel", "")
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"7 cancel now","Count":0}`))
+		})
+
+		It("still routes the instance path", func() {
+			recorder := serve("GET", "/jobs/7", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Name":"7","Count":0}`))
+		})
+
+		It("responds to other methods with a 405 and Allow header", func() {
+			recorder := serve("GET", "/jobs/7/retry", "")
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"OPTIONS", "POST"}))
+
+			recorder = serve("OPTIONS", "/jobs/7/retry", "")
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"OPTIONS", "POST"}))
+		})
+
+		It("calls BeforeHandle", func() {
+			Expect(serve("POST", "/jobs/7/retry", "deny").Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("responds with 404 for unknown actions", func() {
+			Expect(serve("POST", "/jobs/7/explode", "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Bulk Operations", func() {
+		serve := func(method, path, body string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			router.Route(&bulkHandler{})
+		})
+
+		It("replaces collections", func() {
+			recorder := serve("PUT", "/bulk", `[{"Name":"a","Count":1},{"Name":"b","Count":2}]`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"Name":"a","Count":1},{"Name":"b","Count":2}]`))
+		})
+
+		It("reports per-item results for patches with a 207", func() {
+			recorder := serve("PATCH", "/bulk", `[{"Name":"a","Count":1},{"Name":"b","Count":-1}]`)
+			Expect(recorder.Code).To(Equal(http.StatusMultiStatus))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"id": "a", "status": 200, "body": {"Name":"a","Count":1}},
+				{"id": "b", "status": 422, "problem": {"title": "Unprocessable Entity", "status": 422, "detail": "Negative count"}}
+			]`))
+		})
+
+		It("deletes by filter", func() {
+			recorder := serve("DELETE", "/bulk?name=a", "")
+			Expect(recorder.Code).To(Equal(http.StatusMultiStatus))
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"id": "a", "status": 204}]`))
+		})
+
+		It("rejects malformed bodies", func() {
+			Expect(serve("PATCH", "/bulk", `{"Name":"a"}`).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("includes bulk methods in the Allow header", func() {
+			recorder := serve("OPTIONS", "/bulk", "")
+			Expect(recorder.Header()["Allow"]).To(Equal([]string{"DELETE", "OPTIONS", "PATCH", "PUT"}))
+		})
+	})
+
+	Context("Created Resources", func() {
+		post := func(path, body string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		It("responds with 201 and Location headers for Identified bodies", func() {
+			router.Route(&accountHandler{})
+			recorder := post("/accounts", `{"AccountID":"5"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(recorder.Header().Get("Location")).To(Equal("/accounts/5"))
+			Expect(recorder.Header().Get("Content-Location")).To(Equal("/accounts/5"))
+		})
+
+		It("uses CreatedID from Creators, without Content-Location for other bodies", func() {
+			router.Route(&queuedAccountHandler{&accountHandler{created: "9"}})
+			recorder := post("/accounts", `{}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(recorder.Header().Get("Location")).To(Equal("/accounts/9"))
+			Expect(recorder.Header().Get("Content-Location")).To(BeEmpty())
+		})
+
+		It("builds nested Locations", func() {
+			users := &userHandler{users: map[string]bool{"42": true}}
+			router.Route(users)
+			router.Sub(users).Route(&accountHandler{})
+			recorder := post("/users/42/accounts", `{"AccountID":"5"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(recorder.Header().Get("Location")).To(Equal("/users/42/accounts/5"))
+		})
+
+		It("doesn't respond with 201 when there is no item route to point to", func() {
+			router.Route(&ledgerHandler{})
+			recorder := post("/ledger", `{"AccountID":"5"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Location")).To(BeEmpty())
+		})
+
+		It("leaves explicit statuses and empty identifiers alone", func() {
+			router.Route(&accountHandler{status: http.StatusAccepted})
+			recorder := post("/accounts", `{"AccountID":"5"}`)
+			Expect(recorder.Code).To(Equal(http.StatusAccepted))
+			Expect(recorder.Header().Get("Location")).To(BeEmpty())
+
+			recorder = post("/widgets", `{"Name":"foo"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Location")).To(BeEmpty())
+		})
+	})
+
+	Context("Middleware", func() {
+		var calls []string
+
+		record := func(name string) silverback.Middleware {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					resource, _ := silverback.RequestResource(r)
+					calls = append(calls, name+":"+resource.Method)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		BeforeEach(func() {
+			calls = nil
+		})
+
+		It("runs router, resource, and method middleware in order", func() {
+			router.AddMiddleware(record("router"))
+			users := &userHandler{users: map[string]bool{"42": true}}
+			router.Route(users,
+				silverback.WithMethodMiddleware("Get", record("get")),
+				silverback.WithMiddleware(record("resource")),
+			)
+
+			req, err := http.NewRequest("GET", "/users/42", nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(calls).To(Equal([]string{"router:Get", "resource:Get", "get:Get"}))
+		})
+
+		It("exposes the routed handler and only wraps its own routes", func() {
+			var routed silverback.Handler
+			users := &userHandler{users: map[string]bool{"42": true}}
+			router.Route(users, silverback.WithMiddleware(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					resource, _ := silverback.RequestResource(r)
+					routed = resource.Handler
+					next.ServeHTTP(w, r)
+				})
+			}))
+
+			req, err := http.NewRequest("GET", "/users/42", nil)
+			Expect(err).ToNot(HaveOccurred())
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			Expect(routed).To(BeIdenticalTo(users))
+
+			routed = nil
+			req, err = http.NewRequest("POST", "/widgets", bytes.NewBufferString(`{"Name":"foo"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			Expect(routed).To(BeNil())
+		})
+
+		It("runs around parent existence checks in child routers", func() {
+			users := &userHandler{users: map[string]bool{"42": true}}
+			router.Route(users)
+			router.AddMiddleware(record("router"))
+			router.Sub(users).Route(&accountHandler{})
+
+			req, err := http.NewRequest("POST", "/users/7/accounts", bytes.NewBufferString(`{}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(calls).To(Equal([]string{"router:Post"}))
+		})
+	})
+
+	Context("Groups", func() {
+		It("serves the same handler under several prefixes", func() {
+			v1 := router.Group("/v1")
+			v2 := router.Group("/v2")
+			v1.Route(&accountHandler{})
+			v2.Route(&accountHandler{})
+
+			for _, prefix := range []string{"/v1", "/v2"} {
+				req, err := http.NewRequest("POST", prefix+"/accounts", bytes.NewBufferString(`{"AccountID":"5"}`))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("Location")).To(Equal(prefix + "/accounts/5"))
+			}
+		})
+
+		It("includes prefixes in routes and URLs", func() {
+			users := &userHandler{users: map[string]bool{"42": true}}
+			identity := router.Group("identity").Group("/v1")
+			identity.Route(users)
+			identity.Sub(users).Route(&accountHandler{})
+
+			var paths []string
+			for _, route := range router.Routes() {
+				paths = append(paths, route.Path)
+			}
+			Expect(paths).To(ContainElements("/identity/v1/users/{id}", "/identity/v1/users/{parent0}/accounts/{id}"))
+
+			u, err := identity.URLFor(users, "42")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u.String()).To(Equal("/identity/v1/users/42"))
+
+			req, err := http.NewRequest("GET", "/identity/v1/users/7/accounts/5", nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Body.String()).To(ContainSubstring("/identity/v1/users/7"))
+		})
+
+		It("doesn't shadow routes under the same or longer prefixes", func() {
+			router.Group("/v1").Route(&accountHandler{})
+			router.Group("/v1").Route(&widgetHandler{posted: make(chan widget, 1)})
+			router.Group("/api").Route(&accountHandler{})
+			router.Group("/api/v2").Route(&accountHandler{})
+			router.Group("/accounts").Route(&widgetHandler{posted: make(chan widget, 1)})
+			router.Route(&accountHandler{})
+
+			for _, path := range []string{"/v1/accounts", "/v1/widgets", "/api/accounts", "/api/v2/accounts", "/accounts/widgets", "/accounts"} {
+				req, err := http.NewRequest("POST", path, bytes.NewBufferString(`{"Name":"foo","AccountID":"5"}`))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+				Expect(recorder.Code).ToNot(Equal(http.StatusNotFound), path)
+			}
+		})
+
+		It("doesn't prefix paths for a prefix of /", func() {
+			for _, prefix := range []string{"/", ""} {
+				router := silverback.NewRouter()
+				router.AddCodec(&codecs.JSON{})
+				router.Group(prefix).Route(&accountHandler{})
+
+				req, err := http.NewRequest("POST", "/accounts", bytes.NewBufferString(`{"AccountID":"5"}`))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("Location")).To(Equal("/accounts/5"))
+			}
+		})
+
+		It("can't be created from child routers", func() {
+			users := &userHandler{users: map[string]bool{"42": true}}
+			Expect(func() { router.Sub(users).Group("/v1") }).To(Panic())
+		})
+
+		It("overrides codecs and adds middleware for its own routes", func() {
+			var calls []string
+			billing := router.Group("/billing")
+			billing.AddCodec(gob)
+			billing.AddMiddleware(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					calls = append(calls, r.URL.Path)
+					next.ServeHTTP(w, r)
+				})
+			})
+			billing.Route(&widgetHandler{posted: make(chan widget, 1)})
+
+			req, err := http.NewRequest("GET", "/billing/missing", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Header().Get("Content-Type")).ToNot(ContainSubstring("json"))
+
+			for _, path := range []string{"/billing/widgets", "/widgets"} {
+				req, err = http.NewRequest("POST", path, bytes.NewBufferString(`{"Name":"foo"}`))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			Expect(calls).To(Equal([]string{"/billing/widgets"}))
+		})
+	})
+
+	Context("Context Handlers", func() {
+		var reports *reportHandler
+
+		BeforeEach(func() {
+			reports = &reportHandler{started: make(chan bool, 1)}
+			router.SetLanguages("en-US", "fr", "de-DE")
+			router.AddMiddleware(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r.WithContext(silverback.WithPrincipal(r.Context(), "alice")))
+				})
+			})
+			router.Route(reports)
+		})
+
+		get := func(path, language string) report {
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+			if language != "" {
+				req.Header.Set("Accept-Language", language)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var body report
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			return body
+		}
+
+		It("exposes values attached by the router and middleware", func() {
+			body := get("/reports/7", "")
+			Expect(body.ID).To(Equal("7"))
+			Expect(body.Principal).To(Equal("alice"))
+			Expect(body.Codec).To(Equal("application/json"))
+			Expect(body.Vars).To(Equal(map[string]string{"id": "7"}))
+			Expect(body.Language).To(Equal("en-US"))
+		})
+
+		It("negotiates the same codec as the response without an Accept header", func() {
+			req, err := http.NewRequest("GET", "/reports/7", nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var body report
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Codec).To(Equal(recorder.Header().Get("Content-Type")))
+			Expect(body.Codec).To(Equal("application/json"))
+		})
+
+		It("negotiates languages from Accept-Language", func() {
+			Expect(get("/reports/7", "fr-CA, en;q=0.5").Language).To(Equal("fr"))
+			Expect(get("/reports/7", "es, de;q=0.8, fr;q=0.2").Language).To(Equal("de-DE"))
+			Expect(get("/reports/7", "es").Language).To(Equal("en-US"))
+			Expect(get("/reports/7", "fr;q=0, *").Language).To(Equal("en-US"))
+			Expect(get("/reports/7", "fr; q = 0.2, de ; q = 0.8").Language).To(Equal("de-DE"))
+			Expect(get("/reports/7", "EN-gb").Language).To(Equal("en-US"))
+		})
+
+		It("discards responses once the client has gone", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			req, err := http.NewRequestWithContext(ctx, "GET", "/reports/slow", nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				router.ServeHTTP(recorder, req)
+			}()
+			Eventually(reports.started).Should(Receive())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Expect(recorder.Body.Len()).To(BeZero())
+			Expect(recorder.Header().Get("Content-Type")).To(BeEmpty())
+		})
+
+		It("responds with 503 when the request's deadline passes", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", "/reports/slow", nil)
+			Expect(err).ToNot(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(reports.started).To(Receive())
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		})
+	})
+
+	Context("Typed Handlers", func() {
+		send := func(method, path, body string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+			if body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			router.Route(&contactHandler{})
+		})
+
+		It("wraps returned values in responses", func() {
+			recorder := send("GET", "/contacts/1", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Key":"1","Name":"Ada"}`))
+
+			recorder = send("GET", "/contacts", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"Key":"1","Name":"Ada"}]`))
+
+			recorder = send("HEAD", "/contacts/1", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.Len()).To(BeZero())
+		})
+
+		It("decodes request bodies into the typed parameter", func() {
+			recorder := send("POST", "/contacts", `{"Name":"Grace"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(recorder.Header().Get("Location")).To(Equal("/contacts/9"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Key":"9","Name":"Grace"}`))
+
+			recorder = send("PATCH", "/contacts/3", `{"Name":"Grace"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Key":"3","Name":"Grace"}`))
+
+			recorder = send("PATCH", "/contacts/3", `{"Name":`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("decodes request bodies after BeforeHandle", func() {
+			req, err := http.NewRequest("PATCH", "/contacts/3", bytes.NewBufferString(`{"Name":`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "deny")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("maps errors to problems", func() {
+			recorder := send("GET", "/contacts/0", "")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+
+			recorder = send("DELETE", "/contacts/0", "")
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("responds to typed deletes with 204 No Content", func() {
+			recorder := send("DELETE", "/contacts/1", "")
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Body.Len()).To(BeZero())
+		})
+
+		It("reports typed methods in route introspection", func() {
+			var methods map[string][]string
+			for _, route := range router.Routes() {
+				if route.Path == "/contacts/{id}" {
+					methods = route.Methods
+				}
+			}
+			Expect(methods).To(HaveKeyWithValue("GET", []string{"Get"}))
+			Expect(methods).To(HaveKeyWithValue("PATCH", []string{"Patch"}))
+			Expect(methods).To(HaveKeyWithValue("DELETE", []string{"Delete"}))
+		})
+	})
+
+	Context("Error Handlers", func() {
+		send := func(method, path, body string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			router.Route(&orderHandler{})
+		})
+
+		It("routes error-returning methods", func() {
+			recorder := send("GET", "/orders/5", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"Key":"5","Item":"book"}`))
+
+			recorder = send("POST", "/orders", `{"Item":"pen"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("responds with 204 No Content when methods return neither a response nor an error", func() {
+			recorder := send("DELETE", "/orders/5", "")
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Body.Len()).To(BeZero())
+		})
+
+		It("maps well-known errors to problems by default", func() {
+			recorder := send("GET", "/orders/missing", "")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+
+			recorder = send("DELETE", "/orders/shipped", "")
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+			recorder = send("GET", "/orders/unpaid", "")
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("doesn't expose the messages of unmapped errors", func() {
+			recorder := send("GET", "/orders/unpaid", "")
+			Expect(recorder.Body.String()).ToNot(ContainSubstring(errPaymentRequired.Error()))
+
+			recorder = send("GET", "/orders/missing", "")
+			Expect(recorder.Body.String()).ToNot(ContainSubstring("silverback:"))
+		})
+
+		It("lists invalid members for validation errors", func() {
+			recorder := send("POST", "/orders", `{}`)
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			var problem map[string]interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem).To(HaveKeyWithValue("invalid-params", []interface{}{
+				map[string]interface{}{"name": "Item", "reason": "must not be empty"},
+			}))
+		})
+
+		It("uses the router's ErrorMapper, falling back to the default", func() {
+			router.SetErrorMapper(silverback.ErrorMapperFunc(func(req *http.Request, err error) *silverback.Problem {
+				if errors.Is(err, errPaymentRequired) {
+					return silverback.NewProblem(http.StatusPaymentRequired, err.Error())
+				}
+				return nil
+			}))
+			Expect(send("GET", "/orders/unpaid", "").Code).To(Equal(http.StatusPaymentRequired))
+			Expect(send("GET", "/orders/missing", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("doesn't modify problems returned by the ErrorMapper", func() {
+			shared := &silverback.Problem{Title: "Payment Required"}
+			router.SetErrorMapper(silverback.ErrorMapperFunc(func(req *http.Request, err error) *silverback.Problem {
+				return shared
+			}))
+			Expect(send("GET", "/orders/unpaid", "").Code).To(Equal(http.StatusInternalServerError))
+			Expect(shared.Status).To(BeZero())
+		})
+
+		It("maps errors from marshalling response bodies", func() {
+			router.Route(&streamHandler{body: func(context.Context) interface{} {
+				return math.Inf(1)
+			}})
+			recorder := send("GET", "/rows", "")
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Body.String()).ToNot(ContainSubstring("json:"))
+
+			var mapped error
+			router.SetErrorMapper(silverback.ErrorMapperFunc(func(req *http.Request, err error) *silverback.Problem {
+				mapped = err
+				return nil
+			}))
+			send("GET", "/rows", "")
+			var unsupported *json.UnsupportedValueError
+			Expect(errors.As(mapped, &unsupported)).To(BeTrue())
+		})
+
+		It("maps errors from typed handlers", func() {
+			router.Route(&contactHandler{})
+			router.SetErrorMapper(silverback.ErrorMapperFunc(func(req *http.Request, err error) *silverback.Problem {
+				return silverback.NewProblem(http.StatusLocked, err.Error())
+			}))
+			Expect(send("DELETE", "/contacts/0", "").Code).To(Equal(http.StatusLocked))
+		})
+	})
+
+})
@@ -0,0 +1,176 @@
+package silverback
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A RouteInfo describes a path that a Router has routed to a handler.
+// RouteInfo values are returned by Router.Routes.
+type RouteInfo struct {
+	// Path is the gorilla/mux path template of the route, including
+	// any route variables (e.g. "/users/{parent0}/posts/{id}").
+	Path string `json:"path"`
+
+	// Kind is the kind of path: "collection", "item", "singleton", or
+	// "action".
+	Kind string `json:"kind"`
+
+	// Name is the name of the gorilla/mux route, which may be used
+	// with Router.Get.  Names are generated from Kind and Path.
+	Name string `json:"name"`
+
+	// Action is the name of the action, for "action" routes.
+	Action string `json:"action,omitempty"`
+
+	// Methods maps each HTTP method allowed at Path (excluding
+	// OPTIONS, which is always allowed) to the names of the handler
+	// methods that may handle it.  GET requests may be handled by
+	// more than one method; for example, by Watch when the client
+	// prefers an event stream and Get otherwise.
+	Methods map[string][]string `json:"methods"`
+
+	// Vars maps the names of the route variables in Path to the
+	// regular expressions they are restricted to.  Variables without
+	// a pattern (which match a single path segment) map to an empty
+	// string.
+	Vars map[string]string `json:"vars,omitempty"`
+
+	// HandlerType is the Go type of the handler, e.g.
+	// "*api.userHandler".
+	HandlerType string `json:"handlerType"`
+
+	// Handler is the handler that was passed to Route.
+	Handler Handler `json:"-"`
+
+	// Hooks lists the optional interfaces that the handler implements
+	// to customize how its requests are handled, such as
+	// "BeforeHandler" or "BodyReceiver".
+	Hooks []string `json:"hooks,omitempty"`
+
+	// ContentTypes lists the MIME types of the codecs that the route
+	// may use to read and write bodies.
+	ContentTypes []string `json:"contentTypes,omitempty"`
+
+	// settings is the configuration of the router that routed the
+	// path, for ContentTypes.
+	settings *settings
+}
+
+// namedHandler is an http.Handler for one or more handler methods,
+// which remembers the names of those methods for Routes.
+type namedHandler struct {
+	http.Handler
+	names []string
+}
+
+// handlerMethods returns the names of the handler methods that h
+// passes requests to.
+func handlerMethods(h http.Handler) []string {
+	if named, ok := h.(namedHandler); ok {
+		return named.names
+	}
+	return nil
+}
+
+// hookNames returns the names of the optional interfaces that handler
+// implements, other than the ones for its methods.
+func hookNames(handler Handler) []string {
+	var hooks []string
+	add := func(implemented bool, name string) {
+		if implemented {
+			hooks = append(hooks, name)
+		}
+	}
+	_, isBefore := handler.(BeforeHandler)
+	add(isBefore, "BeforeHandler")
+	_, isAfter := handler.(AfterHandler)
+	add(isAfter, "AfterHandler")
+	_, isReceiver := handler.(BodyReceiver)
+	add(isReceiver, "BodyReceiver")
+	_, isIDParser := handler.(IDParser)
+	add(isIDParser, "IDParser")
+	_, isKeyParser := handler.(KeyParser)
+	add(isKeyParser, "KeyParser")
+	_, isLinker := handler.(Linker)
+	add(isLinker, "Linker")
+	_, isContextHandler := handler.(ContextHandler)
+	add(isContextHandler, "ContextHandler")
+	return hooks
+}
+
+// mount routes requests for pattern to the methods in m, recording
+// the route so that it is included in Routes.
+func (r *Router) mount(pattern string, handler Handler, kind, action string, m methods) {
+	info := RouteInfo{
+		Path:        pattern,
+		Kind:        kind,
+		Name:        routeName(kind, pattern),
+		Action:      action,
+		Methods:     make(map[string][]string, len(m)),
+		HandlerType: fmt.Sprintf("%T", handler),
+		Handler:     handler,
+		Hooks:       hookNames(handler),
+	}
+	for method, h := range m {
+		info.Methods[method] = handlerMethods(h)
+	}
+	mapTemplate(pattern, func(name, pattern string) string {
+		if info.Vars == nil {
+			info.Vars = make(map[string]string)
+		}
+		info.Vars[name] = pattern
+		return ""
+	})
+	info.settings = r.settings
+	root := r.settings.root()
+	root.routes = append(root.routes, info)
+	// Groups route with a gorilla/mux subrouter, which adds the prefix
+	// itself.
+	r.Path(strings.TrimPrefix(pattern, r.prefix)).Name(info.Name).Handler(r.allowMethods(m))
+}
+
+// routeName returns the name of the gorilla/mux route of the given
+// kind for pattern.
+func routeName(kind, pattern string) string {
+	return kind + ":" + pattern
+}
+
+// Routes returns a description of every path that has been routed
+// using r, or any router that shares its routes (see Sub and Group),
+// in the order that they were routed.
+func (r *Router) Routes() []RouteInfo {
+	root := r.settings.root()
+	routes := make([]RouteInfo, 0, len(root.routes))
+	for _, route := range root.routes {
+		var contentTypes []string
+		for _, codec := range route.settings.codecs() {
+			for _, typ := range codec.Types() {
+				contentTypes = append(contentTypes, typ.String())
+			}
+		}
+		route.ContentTypes = contentTypes
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// RoutesHandler returns an http.Handler that responds to GET requests
+// with the result of r.Routes(), sorted by path, using r's codecs.  It
+// is not routed by default; mount it wherever it is needed, e.g.:
+//
+//	router.Handle("/debug/routes", router.RoutesHandler())
+func (r *Router) RoutesHandler() http.Handler {
+	get := http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		routes := r.Routes()
+		sort.SliceStable(routes, func(i, j int) bool {
+			return routes[i].Path < routes[j].Path
+		})
+		resp := NewResponse(req)
+		resp.Body = routes
+		WriteResponse(writer, resp, r.codecs())
+	})
+	return r.allowMethods(methods{"GET": get})
+}
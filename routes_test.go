@@ -0,0 +1,79 @@
+package silverback_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+	var router *silverback.Router
+
+	BeforeEach(func() {
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSON{})
+		router.Route(&widgetHandler{})
+		router.Route(&jobHandler{})
+		router.Sub(&ticketHandler{}).Route(&watchHandler{})
+	})
+
+	find := func(path string) silverback.RouteInfo {
+		for _, route := range router.Routes() {
+			if route.Path == path {
+				return route
+			}
+		}
+		Fail("No route found for " + path)
+		return silverback.RouteInfo{}
+	}
+
+	It("describes collection routes", func() {
+		route := find("/widgets")
+		Expect(route.Kind).To(Equal("collection"))
+		Expect(route.Methods).To(Equal(map[string][]string{"POST": {"Post"}}))
+		Expect(route.HandlerType).To(Equal("*silverback_test.widgetHandler"))
+		Expect(route.Hooks).To(ConsistOf("BeforeHandler", "BodyReceiver"))
+		Expect(route.ContentTypes).To(ConsistOf("application/json", "text/json"))
+	})
+
+	It("describes item and action routes", func() {
+		route := find("/jobs/{id}")
+		Expect(route.Kind).To(Equal("item"))
+		Expect(route.Methods).To(Equal(map[string][]string{"GET": {"Get"}, "HEAD": {"Get"}}))
+		Expect(route.Vars).To(Equal(map[string]string{"id": ""}))
+
+		route = find("/jobs/{id}/retry")
+		Expect(route.Kind).To(Equal("action"))
+		Expect(route.Action).To(Equal("retry"))
+		Expect(route.Methods).To(Equal(map[string][]string{"POST": {"Action"}}))
+	})
+
+	It("describes nested routes and their id constraints", func() {
+		route := find("/tickets/{parent0:[0-9]+}/feeds/{id}")
+		Expect(route.Methods).To(HaveKeyWithValue("GET", []string{"Watch", "Get"}))
+		Expect(route.Vars).To(Equal(map[string]string{"parent0": silverback.NumericID, "id": ""}))
+	})
+
+	It("serves the route table from RoutesHandler", func() {
+		router.Handle("/debug/routes", router.RoutesHandler())
+		req, err := http.NewRequest("GET", "/debug/routes", nil)
+		Expect(err).ToNot(HaveOccurred())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var routes []map[string]interface{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &routes)).To(Succeed())
+		Expect(routes).To(HaveLen(len(router.Routes())))
+		Expect(routes[0]).To(HaveKeyWithValue("path", "/jobs/{id}"))
+		Expect(routes[0]).To(HaveKeyWithValue("kind", "item"))
+		Expect(routes[0]).ToNot(HaveKey("Handler"))
+		Expect(routes[0]).ToNot(HaveKey("handler"))
+	})
+})
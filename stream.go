@@ -0,0 +1,76 @@
+package silverback
+
+import (
+	"context"
+	"reflect"
+)
+
+var boolType = reflect.TypeOf(true)
+
+// isStream returns whether or not body is a stream of items, as
+// described in the documentation for Response.
+func isStream(body interface{}) bool {
+	if body == nil {
+		return false
+	}
+	return isStreamType(reflect.TypeOf(body))
+}
+
+func isStreamType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan:
+		return t.ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return false
+		}
+		yield := t.In(0)
+		return yield.Kind() == reflect.Func &&
+			yield.NumIn() == 1 &&
+			yield.NumOut() == 1 &&
+			yield.Out(0) == boolType
+	default:
+		return false
+	}
+}
+
+// eachItem calls fn for each item in the stream body, until the
+// stream ends, fn returns false, or ctx is done.
+func eachItem(ctx context.Context, body interface{}, fn func(item interface{}) bool) {
+	v := reflect.ValueOf(body)
+	if v.Kind() == reflect.Chan {
+		eachChanItem(ctx, v, fn)
+		return
+	}
+	yield := reflect.MakeFunc(v.Type().In(0), func(args []reflect.Value) []reflect.Value {
+		more := ctx.Err() == nil && fn(args[0].Interface())
+		return []reflect.Value{reflect.ValueOf(more)}
+	})
+	v.Call([]reflect.Value{yield})
+}
+
+func eachChanItem(ctx context.Context, ch reflect.Value, fn func(item interface{}) bool) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 0 || !ok {
+			return
+		}
+		if !fn(item.Interface()) {
+			return
+		}
+	}
+}
+
+// collect reads all items from the stream body into a slice.
+func collect(ctx context.Context, body interface{}) []interface{} {
+	items := []interface{}{}
+	eachItem(ctx, body, func(item interface{}) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
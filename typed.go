@@ -0,0 +1,205 @@
+package silverback
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// A TypedGetter is a controller type that handles GET requests for a
+// single instance of a resource, like a Getter, but returns the
+// instance itself rather than a *Response.  The Router wraps the
+// returned value in a Response, or converts the returned error to a
+// problem (see Problem).
+//
+// The typed interfaces can't be detected using type assertions, since
+// the Router doesn't know T; Route detects their methods by their
+// signatures instead.  Assert that a handler implements them to have
+// the compiler check its methods, e.g.:
+//
+//	var _ silverback.TypedGetter[Widget] = (*widgetHandler)(nil)
+type TypedGetter[T any] interface {
+	Handler
+	Get(ctx context.Context, identifier string) (T, error)
+}
+
+// A TypedQuerier is the typed equivalent of a Querier.  See
+// TypedGetter.
+type TypedQuerier[T any] interface {
+	Handler
+	Query(ctx context.Context) (T, error)
+}
+
+// A TypedPoster is the typed equivalent of a Poster.  The request body
+// is decoded into a new In, using the codec matching the request's
+// Content-Type header, and passed to Post.  If the returned Out is
+// Identified, the Router responds with 201 Created (see Creator).
+// See TypedGetter.
+type TypedPoster[In, Out any] interface {
+	Handler
+	Post(ctx context.Context, body In) (Out, error)
+}
+
+// A TypedPutter is the typed equivalent of a Putter.  See TypedPoster.
+type TypedPutter[In, Out any] interface {
+	Handler
+	Put(ctx context.Context, identifier string, body In) (Out, error)
+}
+
+// A TypedPatcher is the typed equivalent of a Patcher.  See
+// TypedPoster.
+type TypedPatcher[In, Out any] interface {
+	Handler
+	Patch(ctx context.Context, identifier string, body In) (Out, error)
+}
+
+// A TypedDeleter is the typed equivalent of a Deleter.  The Router
+// responds with 204 No Content when Delete returns a nil error.
+type TypedDeleter interface {
+	Handler
+	Delete(ctx context.Context, identifier string) error
+}
+
+// typedSignature describes the parameters and results of a typed
+// handler method, after its context.
+type typedSignature struct {
+	id, body, out bool
+}
+
+// typedSignatures maps the names of the typed handler methods to
+// their signatures.
+var typedSignatures = map[string]typedSignature{
+	"Get":    {id: true, out: true},
+	"Query":  {out: true},
+	"Post":   {body: true, out: true},
+	"Put":    {id: true, body: true, out: true},
+	"Patch":  {id: true, body: true, out: true},
+	"Delete": {id: true},
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	stringType  = reflect.TypeOf("")
+)
+
+// typedMethod is a method of a typed handler (see TypedGetter).
+type typedMethod struct {
+	typedSignature
+	name string
+
+	// bodyType and resultType are the types of the request body and
+	// the result; they are nil when the method has no such value.
+	bodyType, resultType reflect.Type
+}
+
+// findTypedMethod returns handler's typed method called name, if it
+// has one with the right signature.
+func findTypedMethod(handler Handler, name string) (typedMethod, bool) {
+	sig, ok := typedSignatures[name]
+	if !ok {
+		return typedMethod{}, false
+	}
+	method, ok := reflect.TypeOf(handler).MethodByName(name)
+	if !ok {
+		return typedMethod{}, false
+	}
+	m := typedMethod{typedSignature: sig, name: name}
+	// The receiver is the method's first parameter.
+	params := []reflect.Type{contextType}
+	if sig.id {
+		params = append(params, stringType)
+	}
+	t := method.Type
+	numIn := len(params) + 1
+	if sig.body {
+		numIn++
+	}
+	if t.NumIn() != numIn {
+		return typedMethod{}, false
+	}
+	for i, param := range params {
+		if t.In(i+1) != param {
+			return typedMethod{}, false
+		}
+	}
+	if sig.body {
+		m.bodyType = t.In(numIn - 1)
+	}
+	results := 1
+	if sig.out {
+		results++
+	}
+	if t.NumOut() != results || t.Out(results-1) != errorType {
+		return typedMethod{}, false
+	}
+	if sig.out {
+		m.resultType = t.Out(0)
+	}
+	return m, true
+}
+
+// isGetter returns whether or not handler handles GET requests for its
+// instances, as a Getter, an ErrorGetter, or a TypedGetter.
+func isGetter(handler Handler) bool {
+	if _, ok := getMethod(handler, nil); ok {
+		return true
+	}
+	_, ok := findTypedMethod(handler, "Get")
+	return ok
+}
+
+// typedHandler returns the http.Handler for requests to a typed
+// method of handler.  Responses to HEAD requests are written without
+// a body.
+func (r *Router) typedHandler(handler Handler, m typedMethod, head bool) http.Handler {
+	if head {
+		return r.resourceHandler(handler, m.name, cancelAfter(func(writer http.ResponseWriter, req *http.Request) {
+			r.respondHead(writer, req, r.typedResponse(handler, m, req))
+		}))
+	}
+	return r.resourceHandler(handler, m.name, func(writer http.ResponseWriter, req *http.Request) {
+		r.respond(writer, req, r.typedResponse(handler, m, req))
+	})
+}
+
+// typedResponse calls the typed method m on the handler for req,
+// wrapping its result in a Response.
+func (r *Router) typedResponse(handler Handler, m typedMethod, req *http.Request) *Response {
+	h := r.newHandler(handler, req)
+	call := func(id string) *Response {
+		args := []reflect.Value{reflect.ValueOf(r.requestContext(req))}
+		if m.id {
+			args = append(args, reflect.ValueOf(id))
+		}
+		if m.body {
+			// The body is decoded after the handler's hooks have
+			// run, like a BodyReceiver's.
+			body := reflect.New(m.bodyType)
+			if problem := r.decodeBody(req, body.Interface()); problem != nil {
+				return newProblemResponse(req, problem)
+			}
+			args = append(args, body.Elem())
+		}
+		results := reflect.ValueOf(h).MethodByName(m.name).Call(args)
+		if err, _ := results[len(results)-1].Interface().(error); err != nil {
+			return problemResponse(req, err)
+		}
+		resp := NewResponse(req)
+		if !m.out {
+			resp.Status = http.StatusNoContent
+			return resp
+		}
+		resp.Body = results[0].Interface()
+		if m.name == "Post" {
+			resp = created(h, req, resp)
+		}
+		return resp
+	}
+	if m.id {
+		return r.idHandle(h, req, false, call, requestResource(req).ID)
+	}
+	return r.handle(h, req, false, func() *Response {
+		return call("")
+	})
+}
@@ -0,0 +1,176 @@
+package silverback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// ErrNoRoute is returned when building a URL for a handler that
+// hasn't been routed.
+var ErrNoRoute = errors.New("silverback: no route found for handler")
+
+// URLFor returns the URL of the instance of handler identified by id,
+// as routed by r.  For handlers routed by a child router (see Sub),
+// URLFor must be called on that child router, and parentIDs must
+// identify each parent instance, outermost first.  For Singleton
+// handlers, id is ignored.
+func (r *Router) URLFor(handler Handler, id string, parentIDs ...string) (*url.URL, error) {
+	kind, pattern := "item", path.Join(r.routePath(handler.Path()), idRoute(handler, "id"))
+	if isSingleton(handler) {
+		kind, pattern = "singleton", r.routePath(handler.Path())
+	}
+	return r.buildURL(handler, kind, pattern, id, parentIDs)
+}
+
+// CollectionURLFor returns the URL of handler's collection, as routed
+// by r.  parentIDs are used in the same way as in URLFor.  Only
+// handlers with collection methods (such as Query or Post) have a
+// collection route; for others, the returned error wraps ErrNoRoute.
+func (r *Router) CollectionURLFor(handler Handler, parentIDs ...string) (*url.URL, error) {
+	return r.buildURL(handler, "collection", r.routePath(handler.Path()), "", parentIDs)
+}
+
+// buildURL builds a URL using the gorilla/mux route of the given kind
+// for pattern.
+func (r *Router) buildURL(handler Handler, kind, pattern, id string, parentIDs []string) (*url.URL, error) {
+	route := r.Get(routeName(kind, pattern))
+	if route == nil {
+		return nil, fmt.Errorf("%w: %T at %s", ErrNoRoute, handler, pattern)
+	}
+	if len(parentIDs) != len(r.parents) {
+		return nil, fmt.Errorf("silverback: %d parent identifiers are required for %s, got %d", len(r.parents), pattern, len(parentIDs))
+	}
+	vars := map[string]string{}
+	for i, parent := range r.parents {
+		if isSingleton(parent.handler) {
+			continue
+		}
+		if err := addIDVars(vars, parent.handler, parent.idVar, parentIDs[i]); err != nil {
+			return nil, err
+		}
+	}
+	if kind == "item" {
+		if err := addIDVars(vars, handler, "id", id); err != nil {
+			return nil, err
+		}
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, name, value)
+	}
+	return route.URL(pairs...)
+}
+
+// URLFor returns the URL of the instance of handler identified by id,
+// from within a request routed by a Router.  The request's parent
+// identifiers are used for nested routes, so handlers may build URLs
+// for themselves, their siblings, and their parents without knowing
+// where they were mounted.
+func URLFor(req *http.Request, handler Handler, id string) (*url.URL, error) {
+	return requestURL(req, handler, func(r *Router, parentIDs []string) (*url.URL, error) {
+		return r.URLFor(handler, id, parentIDs...)
+	})
+}
+
+// CollectionURLFor returns the URL of handler's collection, from
+// within a request routed by a Router.  See URLFor.
+func CollectionURLFor(req *http.Request, handler Handler) (*url.URL, error) {
+	return requestURL(req, handler, func(r *Router, parentIDs []string) (*url.URL, error) {
+		return r.CollectionURLFor(handler, parentIDs...)
+	})
+}
+
+// requestURL calls build with the router that routed req, then each of
+// its ancestors in turn, until a URL is built for handler.
+func requestURL(req *http.Request, handler Handler, build func(*Router, []string) (*url.URL, error)) (*url.URL, error) {
+	resource := requestResource(req)
+	if resource == nil || resource.router == nil {
+		return nil, fmt.Errorf("%w: request was not routed by a Router", ErrNoRoute)
+	}
+	r := resource.router
+	for depth := len(r.parents); depth >= 0; depth-- {
+		ancestor := &Router{
+			Router:   r.Router,
+			settings: r.settings,
+			parents:  r.parents[:depth],
+			prefix:   r.prefix,
+		}
+		parentIDs := make([]string, 0, depth)
+		for _, parent := range resource.Parents[:depth] {
+			parentIDs = append(parentIDs, parent.ID)
+		}
+		u, err := build(ancestor, parentIDs)
+		if !errors.Is(err, ErrNoRoute) {
+			return u, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %T", ErrNoRoute, handler)
+}
+
+// addIDVars adds the route variables for the instance of handler
+// identified by id to vars.
+func addIDVars(vars map[string]string, handler Handler, varName, id string) error {
+	templater, ok := handler.(IDTemplater)
+	if !ok {
+		vars[varName] = id
+		return nil
+	}
+	tmpl := templater.IDTemplate()
+	match, names, err := templateRegexp(tmpl)
+	if err != nil {
+		return err
+	}
+	values := match.FindStringSubmatch(id)
+	if values == nil {
+		return fmt.Errorf("silverback: identifier %q doesn't match template %q", id, tmpl)
+	}
+	for i, name := range names {
+		vars[varName+"."+name] = values[match.SubexpIndex(templateGroup(i))]
+	}
+	return nil
+}
+
+// created updates resp for a Post that created a resource instance,
+// setting its status to 201 Created and adding Location and
+// Content-Location headers.  See Creator and TypedPoster.
+func created(h Handler, req *http.Request, resp *Response) *Response {
+	if resp == nil || isSingleton(h) {
+		return resp
+	}
+	if resp.Status != 0 && resp.Status != http.StatusCreated {
+		return resp
+	}
+	if _, isProblem := resp.Body.(*Problem); isProblem {
+		return resp
+	}
+	identified, bodyIdentified := resp.Body.(Identified)
+	var id string
+	if creator, ok := h.(Creator); ok {
+		id = creator.CreatedID()
+	} else if bodyIdentified {
+		id = identified.ID()
+	}
+	if id == "" {
+		return resp
+	}
+	location, err := URLFor(req, h, id)
+	if err != nil {
+		// Without an item route, there's nowhere to point to, so
+		// the response isn't upgraded to 201 Created.
+		return resp
+	}
+	resp.Status = http.StatusCreated
+	if resp.Headers == nil {
+		resp.Headers = make(http.Header)
+	}
+	if resp.Headers.Get("Location") == "" {
+		resp.Headers.Set("Location", location.String())
+	}
+	if bodyIdentified && identified.ID() == id && resp.Headers.Get("Content-Location") == "" {
+		resp.Headers.Set("Content-Location", location.String())
+	}
+	return resp
+}
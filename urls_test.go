@@ -0,0 +1,110 @@
+package silverback_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/nelsam/silverback"
+	"github.com/nelsam/silverback/codecs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type linkHandler struct {
+	req    *http.Request
+	parent silverback.Handler
+}
+
+func (h *linkHandler) New(r *http.Request) silverback.Handler {
+	return &linkHandler{req: r, parent: h.parent}
+}
+
+func (h *linkHandler) Path() string {
+	return "/links"
+}
+
+func (h *linkHandler) Get(id string) *silverback.Response {
+	self, err := silverback.URLFor(h.req, h, id)
+	Expect(err).ToNot(HaveOccurred())
+	collection, err := silverback.CollectionURLFor(h.req, h)
+	Expect(err).ToNot(HaveOccurred())
+	parent, err := silverback.URLFor(h.req, h.parent, "99")
+	Expect(err).ToNot(HaveOccurred())
+	resp := silverback.NewResponse(h.req)
+	resp.Body = []string{self.String(), collection.String(), parent.String()}
+	return resp
+}
+
+func (h *linkHandler) Query() *silverback.Response {
+	return silverback.NewResponse(h.req)
+}
+
+var _ = Describe("URLFor", func() {
+	var (
+		router *silverback.Router
+		users  *userHandler
+		posts  *postHandler
+	)
+
+	BeforeEach(func() {
+		router = silverback.NewRouter()
+		router.AddCodec(&codecs.JSON{})
+		users = &userHandler{users: map[string]bool{"42": true}}
+		posts = &postHandler{called: make(chan bool, 1)}
+		router.Route(users)
+		router.Route(&rateHandler{})
+		router.Route(&settingsHandler{})
+		router.Sub(users).Route(posts)
+	})
+
+	It("builds item and collection URLs", func() {
+		u, err := router.URLFor(users, "42")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.String()).To(Equal("/users/42"))
+
+		u, err = router.Sub(users).CollectionURLFor(posts, "42")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.String()).To(Equal("/users/42/posts"))
+
+		_, err = router.CollectionURLFor(users)
+		Expect(err).To(MatchError(silverback.ErrNoRoute))
+	})
+
+	It("builds URLs for composite identifiers and singletons", func() {
+		u, err := router.URLFor(&rateHandler{}, "USD/2024-01-01")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.String()).To(Equal("/rates/USD/2024-01-01"))
+
+		_, err = router.URLFor(&rateHandler{}, "usd/2024-01-01")
+		Expect(err).To(HaveOccurred())
+
+		u, err = router.URLFor(&settingsHandler{}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.String()).To(Equal("/settings"))
+	})
+
+	It("honors child router mount points", func() {
+		u, err := router.Sub(users).URLFor(posts, "7", "42")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.String()).To(Equal("/users/42/posts/7"))
+
+		_, err = router.Sub(users).URLFor(posts, "7")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns ErrNoRoute for handlers that haven't been routed", func() {
+		_, err := router.URLFor(&jobHandler{}, "1")
+		Expect(err).To(MatchError(silverback.ErrNoRoute))
+	})
+
+	It("builds URLs from within requests", func() {
+		router.Sub(users).Route(&linkHandler{parent: users})
+		req, err := http.NewRequest("GET", "/users/42/links/3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`["/users/42/links/3", "/users/42/links", "/users/99"]`))
+	})
+})